@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/Philip-Machar/clario/internal/db"
 	"github.com/Philip-Machar/clario/internal/handlers"
+	"github.com/Philip-Machar/clario/internal/jobs"
+	"github.com/Philip-Machar/clario/internal/llm"
 	authMiddleware "github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/prompts"
 	"github.com/Philip-Machar/clario/internal/repository"
 	"github.com/Philip-Machar/clario/internal/service"
 	"github.com/go-chi/chi/v5"
@@ -32,14 +39,67 @@ func main() {
 	taskRepo := repository.NewTaskRepository(database)
 	userRepo := repository.NewUserRepository(database)
 	chatRepo := repository.NewChatRepository(database)
+	actionRepo := repository.NewActionRepository(database)
+	memoryRepo := repository.NewMemoryRepository(database)
+	planRepo := repository.NewPlanRepository(database)
+	reviewRepo := repository.NewReviewRepository(database)
+	usageRepo := repository.NewUsageRepository(database)
+	nudgeRepo := repository.NewNudgeRepository(database)
+	tagRepo := repository.NewTagRepository(database)
+	projectRepo := repository.NewProjectRepository(database)
+
+	//language model backend (gemini, openai or fake, see LLM_PROVIDER)
+	backend, err := llm.New(context.Background(), llm.ConfigFromEnv())
+
+	if err != nil {
+		log.Fatal("Failed to set up LLM provider: ", err)
+	}
+
+	//timeouts, retries and a circuit breaker around every model call
+	mentorModel := llm.NewResilient(backend, llm.PolicyFromEnv())
+
+	//how many tokens of conversation the mentor replays, summary included
+	historyBudget, _ := strconv.Atoi(os.Getenv("MENTOR_HISTORY_TOKENS"))
+
+	if historyBudget > 0 && historyBudget < service.MinHistoryBudget {
+		log.Fatalf("MENTOR_HISTORY_TOKENS must be at least %d, got %d", service.MinHistoryBudget, historyBudget)
+	}
+
+	//prompt templates, PROMPT_DIR overrides the embedded ones without recompiling
+	promptRenderer, err := prompts.NewRenderer(os.Getenv("PROMPT_DIR"), os.Getenv("PROMPT_VERSION"))
+
+	if err != nil {
+		log.Fatal("Failed to load prompt templates: ", err)
+	}
 
 	//services
-	aiService := service.NewAIService(chatRepo, taskRepo)
+	usageMeter := service.NewUsageMeter(usageRepo, service.LimitsFromEnv())
+	summarizer := service.NewSummarizer(mentorModel, chatRepo, historyBudget, usageMeter)
+	undoWindow := service.UndoWindowFromEnv()
+	trashPurger := service.NewPurger("tasks in the trash", service.TrashRetentionFromEnv(), taskRepo.PurgeTrash)
+	operationPurger := service.NewPurger("task operations", undoWindow, taskRepo.PurgeOperations)
+	aiService := service.NewAIService(mentorModel, chatRepo, taskRepo, actionRepo, memoryRepo, userRepo, planRepo, reviewRepo, nudgeRepo, summarizer, promptRenderer, usageMeter)
 
 	//Handlers
-	taskHandler := handlers.NewTaskHandler(taskRepo)
+	taskHandler := handlers.NewTaskHandler(taskRepo, undoWindow)
 	authHandler := handlers.NewAuthHandler(userRepo)
 	aiHandler := handlers.NewAIHandler(aiService)
+	chatHandler := handlers.NewChatHandler(chatRepo)
+	memoryHandler := handlers.NewMemoryHandler(memoryRepo)
+	healthHandler := handlers.NewHealthHandler(mentorModel)
+	usageHandler := handlers.NewUsageHandler(usageMeter)
+	nudgeHandler := handlers.NewNudgeHandler(chatRepo, nudgeRepo)
+	tagHandler := handlers.NewTagHandler(tagRepo)
+	projectHandler := handlers.NewProjectHandler(projectRepo)
+
+	//background jobs, checked every minute
+	scheduler := jobs.NewScheduler(time.Minute,
+		jobs.JobFunc{JobName: "nudges", Func: aiService.RunNudges},
+		jobs.JobFunc{JobName: "trash-purge", Func: trashPurger.Run},
+		jobs.JobFunc{JobName: "undo-purge", Func: operationPurger.Run},
+	)
+
+	go scheduler.Run(context.Background())
 
 	//create a new router
 	r := chi.NewRouter()
@@ -51,7 +111,8 @@ func main() {
 			"https://*.vercel.app",
 		},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Client"},
+		ExposedHeaders:   []string{"X-Undo-Token"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -63,6 +124,7 @@ func main() {
 	//PUBLIC ROUTES
 	r.Post("/register", authHandler.RegisterUser)
 	r.Post("/login", authHandler.Login)
+	r.Get("/health", healthHandler.Health)
 
 	//PROCTECTED ROUTES (token required)
 	r.Group(func(r chi.Router) {
@@ -73,9 +135,65 @@ func main() {
 		r.Delete("/task/{id}", taskHandler.Delete)
 		r.Put("/task/{id}", taskHandler.Update)
 		r.Put("/task/{id}/status", taskHandler.UpdateStatus)
+		r.Put("/task/{id}/project", taskHandler.MoveToProject)
+		r.Get("/task/{id}/history", taskHandler.GetHistory)
+		r.Post("/task/{id}/restore", taskHandler.Restore)
+		r.Get("/trash", taskHandler.GetTrash)
+		r.Post("/undo", taskHandler.Undo)
+		r.Post("/redo", taskHandler.Redo)
+		r.Delete("/trash", taskHandler.EmptyTrash)
+		r.Delete("/trash/{id}", taskHandler.DeletePermanently)
+		r.Get("/task/{id}/subtasks", taskHandler.GetSubtasks)
+		r.Post("/task/{id}/subtasks", taskHandler.AddSubtask)
+		r.Put("/task/{id}/subtasks/order", taskHandler.ReorderSubtasks)
+		r.Post("/task/{id}/breakdown", aiHandler.BreakdownTask)
+		r.Post("/task/{id}/breakdown/accept", aiHandler.AcceptBreakdown)
+		r.Get("/series/{id}", taskHandler.GetSeries)
+		r.Get("/tags", tagHandler.GetAll)
+		r.Post("/tags", tagHandler.Create)
+		r.Put("/tags/{id}", tagHandler.Update)
+		r.Delete("/tags/{id}", tagHandler.Delete)
+		r.Get("/projects", projectHandler.GetAll)
+		r.Post("/projects", projectHandler.Create)
+		r.Put("/projects/order", projectHandler.Reorder)
+		r.Get("/projects/{id}", projectHandler.Get)
+		r.Put("/projects/{id}", projectHandler.Update)
+		r.Delete("/projects/{id}", projectHandler.Delete)
 		r.Get("/streak", taskHandler.GetCurrentStreaks)
 		r.Get("/heatmap", taskHandler.GetMonthlyHeatmap)
 		r.Post("/chat", aiHandler.ChatWithMentor)
+		r.Post("/chat/stream", aiHandler.ChatStream)
+		r.Get("/chat/history", chatHandler.GetHistory)
+		r.Delete("/chat/history", chatHandler.ClearHistory)
+		r.Delete("/chat/history/{id}", chatHandler.DeleteMessage)
+		r.Get("/chat/actions", aiHandler.GetPendingActions)
+		r.Post("/chat/actions/{id}/confirm", aiHandler.ConfirmAction)
+		r.Post("/chat/actions/{id}/cancel", aiHandler.CancelAction)
+		r.Get("/chat/threads", chatHandler.GetThreads)
+		r.Post("/chat/threads", chatHandler.CreateThread)
+		r.Put("/chat/threads/{id}", chatHandler.RenameThread)
+		r.Put("/chat/threads/{id}/archive", chatHandler.ArchiveThread)
+		r.Get("/mentor/personas", aiHandler.GetPersonas)
+		r.Put("/mentor/persona", aiHandler.SetPersona)
+		r.Get("/mentor/prompt/preview", aiHandler.PreviewPrompt)
+		r.Post("/plan/today", aiHandler.PlanToday)
+		r.Get("/plan/today", aiHandler.GetTodayPlan)
+		r.Post("/plan/{id}/accept", aiHandler.AcceptPlan)
+		r.Get("/reviews", aiHandler.GetReviews)
+		r.Post("/reviews", aiHandler.CreateReview)
+		r.Get("/reviews/{id}", aiHandler.GetReview)
+		r.Get("/memories", memoryHandler.GetAll)
+		r.Post("/memories", memoryHandler.Create)
+		r.Put("/memories/{id}", memoryHandler.Update)
+		r.Delete("/memories/{id}", memoryHandler.Delete)
+		r.Get("/usage", usageHandler.GetUsage)
+		r.Get("/nudges", nudgeHandler.GetUnread)
+		r.Post("/nudges/read", nudgeHandler.MarkRead)
+		r.Post("/nudges/{id}/read", nudgeHandler.MarkRead)
+		r.Get("/nudges/settings", nudgeHandler.GetSettings)
+		r.Put("/nudges/settings", nudgeHandler.UpdateSettings)
+
+		r.With(authMiddleware.AdminOnly(userRepo.IsAdmin)).Get("/admin/usage", usageHandler.GetReport)
 	})
 
 	//starting server and listening ap port 8080
@@ -0,0 +1,329 @@
+// Package eval checks mentor replies against scenarios written in YAML. The scenarios
+// run as tests behind the "eval" build tag:
+//
+//	go test -tags eval ./internal/eval/ -v
+//
+// They use the fake provider unless EVAL_PROVIDER names another one (configured from
+// the usual LLM_* variables), and the built-in prompts unless PROMPT_DIR or
+// PROMPT_VERSION say otherwise, so a prompt change can be checked before shipping it.
+package eval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is one situation the mentor has to handle well
+type Scenario struct {
+	Name     string          `yaml:"name"`
+	Persona  string          `yaml:"persona"` // empty means the default persona
+	Now      time.Time       `yaml:"now"`
+	Tasks    []TaskFixture   `yaml:"tasks"`
+	Memories []MemoryFixture `yaml:"memories"`
+	Summary  string          `yaml:"summary"`
+	History  []Turn          `yaml:"history"`
+	Message  string          `yaml:"message"`
+
+	// what retrieval found for the message, as if the search had run
+	RelatedTasks    []TaskFixture    `yaml:"related_tasks"`
+	RelatedMessages []MessageFixture `yaml:"related_messages"`
+
+	// FakeReply is what the fake provider answers; other providers ignore it
+	FakeReply string `yaml:"fake_reply"`
+
+	PromptAsserts []Assertion `yaml:"prompt_asserts"` // checked against the rendered prompt
+	Asserts       []Assertion `yaml:"asserts"`        // checked against the reply
+
+	File string `yaml:"-"`
+}
+
+type TaskFixture struct {
+	ID          int    `yaml:"id"`
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Status      string `yaml:"status"`
+	Priority    string `yaml:"priority"`
+	Due         string `yaml:"due"`         // YYYY-MM-DD
+	Completed   string `yaml:"completed"`   // YYYY-MM-DD
+	Rescheduled int    `yaml:"rescheduled"` // times its due date was pushed back
+}
+
+type MemoryFixture struct {
+	Kind    string `yaml:"kind"`
+	Content string `yaml:"content"`
+}
+
+type MessageFixture struct {
+	Date    string `yaml:"date"` // YYYY-MM-DD
+	Role    string `yaml:"role"`
+	Content string `yaml:"content"`
+}
+
+type Turn struct {
+	Role    string `yaml:"role"`
+	Content string `yaml:"content"`
+}
+
+// Assertion is one check. Set exactly one field.
+type Assertion struct {
+	MentionsOverdueCount bool   `yaml:"mentions_overdue_count"`
+	MaxSentences         int    `yaml:"max_sentences"`
+	DoesNotShame         bool   `yaml:"does_not_shame"`
+	Contains             string `yaml:"contains"`
+	NotContains          string `yaml:"not_contains"`
+	Matches              string `yaml:"matches"` // regular expression
+}
+
+// LoadScenarios reads every .yaml file in dir
+func LoadScenarios(dir string) ([]Scenario, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	scenarios := make([]Scenario, 0, len(files))
+
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var scenario Scenario
+
+		if err := yaml.Unmarshal(raw, &scenario); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		if scenario.Name == "" {
+			scenario.Name = strings.TrimSuffix(filepath.Base(file), ".yaml")
+		}
+
+		if scenario.Now.IsZero() {
+			return nil, fmt.Errorf("%s: now is required so the scenario does not depend on the day it runs", file)
+		}
+
+		scenario.File = file
+		scenarios = append(scenarios, scenario)
+	}
+
+	return scenarios, nil
+}
+
+// TaskModels turns the task fixtures into tasks, dates in the scenario's time zone
+func (s Scenario) TaskModels() ([]models.Task, error) {
+	return s.tasks(s.Tasks)
+}
+
+// RelatedTaskModels is TaskModels for the tasks retrieval found
+func (s Scenario) RelatedTaskModels() ([]models.Task, error) {
+	return s.tasks(s.RelatedTasks)
+}
+
+// RescheduleCounts is what the task history would say about how often each task was postponed
+func (s Scenario) RescheduleCounts() map[int]int {
+	counts := map[int]int{}
+
+	for i, fixture := range s.Tasks {
+		id := fixture.ID
+
+		if id == 0 {
+			id = i + 1
+		}
+
+		if fixture.Rescheduled > 0 {
+			counts[id] = fixture.Rescheduled
+		}
+	}
+
+	return counts
+}
+
+func (s Scenario) RelatedMessageModels() ([]models.ChatMessage, error) {
+	messages := make([]models.ChatMessage, 0, len(s.RelatedMessages))
+
+	for i, fixture := range s.RelatedMessages {
+		date, err := s.date(fixture.Date)
+
+		if err != nil || date == nil {
+			return nil, fmt.Errorf("related message %d: date %q, expected YYYY-MM-DD", i+1, fixture.Date)
+		}
+
+		messages = append(messages, models.ChatMessage{ID: i + 1, Role: fixture.Role, Message: fixture.Content, CreatedAt: *date})
+	}
+
+	return messages, nil
+}
+
+func (s Scenario) tasks(fixtures []TaskFixture) ([]models.Task, error) {
+	tasks := make([]models.Task, 0, len(fixtures))
+
+	for i, fixture := range fixtures {
+		task := models.Task{
+			ID:          fixture.ID,
+			Title:       fixture.Title,
+			Description: fixture.Description,
+			Status:      fixture.Status,
+			Priority:    fixture.Priority,
+		}
+
+		if task.ID == 0 {
+			task.ID = i + 1
+		}
+
+		if task.Status == "" {
+			task.Status = "todo"
+		}
+
+		if task.Priority == "" {
+			task.Priority = "medium"
+		}
+
+		var err error
+
+		if task.DueDate, err = s.date(fixture.Due); err != nil {
+			return nil, fmt.Errorf("task %d due: %w", task.ID, err)
+		}
+
+		if task.CompletedAt, err = s.date(fixture.Completed); err != nil {
+			return nil, fmt.Errorf("task %d completed: %w", task.ID, err)
+		}
+
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+func (s Scenario) MemoryModels() []models.MentorMemory {
+	memories := make([]models.MentorMemory, 0, len(s.Memories))
+
+	for i, fixture := range s.Memories {
+		memories = append(memories, models.MentorMemory{ID: i + 1, Kind: fixture.Kind, Content: fixture.Content})
+	}
+
+	return memories
+}
+
+func (s Scenario) date(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", value, s.Now.Location())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &date, nil
+}
+
+// Check runs assertion against text. overdue is the overdue count the mentor was given.
+// It returns a description of the failure, or "" if the assertion holds.
+func Check(assertion Assertion, text string, overdue int) string {
+	lower := strings.ToLower(text)
+
+	switch {
+	case assertion.MentionsOverdueCount:
+		if !mentionsNumber(lower, overdue) {
+			return fmt.Sprintf("does not mention the overdue count %d", overdue)
+		}
+	case assertion.MaxSentences > 0:
+		if n := CountSentences(text); n > assertion.MaxSentences {
+			return fmt.Sprintf("has %d sentences, want at most %d", n, assertion.MaxSentences)
+		}
+	case assertion.DoesNotShame:
+		if phrase := ShamingPhrase(lower); phrase != "" {
+			return fmt.Sprintf("shames the user (%q)", phrase)
+		}
+	case assertion.Contains != "":
+		if !strings.Contains(lower, strings.ToLower(assertion.Contains)) {
+			return fmt.Sprintf("does not contain %q", assertion.Contains)
+		}
+	case assertion.NotContains != "":
+		if strings.Contains(lower, strings.ToLower(assertion.NotContains)) {
+			return fmt.Sprintf("contains %q", assertion.NotContains)
+		}
+	case assertion.Matches != "":
+		re, err := regexp.Compile(assertion.Matches)
+
+		if err != nil {
+			return fmt.Sprintf("bad pattern %q: %v", assertion.Matches, err)
+		}
+
+		if !re.MatchString(text) {
+			return fmt.Sprintf("does not match %q", assertion.Matches)
+		}
+	default:
+		return "empty assertion"
+	}
+
+	return ""
+}
+
+var numberWords = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten", "eleven", "twelve"}
+
+// mentionsNumber looks for n as digits or, for small numbers, as a word
+func mentionsNumber(lower string, n int) bool {
+	candidates := []string{strconv.Itoa(n)}
+
+	if n < len(numberWords) {
+		candidates = append(candidates, numberWords[n])
+	}
+
+	if n == 0 {
+		candidates = append(candidates, "no overdue", "nothing overdue", "nothing is overdue", "none overdue", "none are overdue")
+	}
+
+	for _, candidate := range candidates {
+		if regexp.MustCompile(`\b` + regexp.QuoteMeta(candidate) + `\b`).MatchString(lower) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var sentenceEnd = regexp.MustCompile(`[.!?]+(\s+|$)`)
+
+// CountSentences counts sentences by their closing punctuation; trailing text without any counts as one
+func CountSentences(text string) int {
+	count := 0
+
+	for _, part := range sentenceEnd.Split(strings.TrimSpace(text), -1) {
+		if strings.TrimSpace(part) != "" {
+			count++
+		}
+	}
+
+	return count
+}
+
+// shamingPhrases are put-downs a mentor must never use, honest criticism is fine
+var shamingPhrases = []string{
+	"lazy", "pathetic", "useless", "worthless", "hopeless", "ashamed", "shame on you",
+	"disappointment", "disappointing", "you're a failure", "you are a failure", "loser",
+	"what is wrong with you", "what's wrong with you", "embarrassing", "you should be embarrassed",
+}
+
+// ShamingPhrase returns the first shaming phrase in lower, or ""
+func ShamingPhrase(lower string) string {
+	for _, phrase := range shamingPhrases {
+		if regexp.MustCompile(`\b` + regexp.QuoteMeta(phrase) + `\b`).MatchString(lower) {
+			return phrase
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,134 @@
+//go:build eval
+
+package eval
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Philip-Machar/clario/internal/llm"
+	"github.com/Philip-Machar/clario/internal/prompts"
+	"github.com/Philip-Machar/clario/internal/service"
+)
+
+// evalModel is the provider named by EVAL_PROVIDER, the fake one by default.
+// A fake model answers each scenario with its fake_reply.
+func evalModel(t *testing.T, scenario Scenario) llm.MentorModel {
+	provider := os.Getenv("EVAL_PROVIDER")
+
+	if provider == "" || provider == "fake" {
+		fake := llm.NewFake()
+
+		if scenario.FakeReply != "" {
+			fake.Respond = func(req *llm.Request) (*llm.Reply, error) {
+				return &llm.Reply{Text: scenario.FakeReply}, nil
+			}
+		}
+
+		return fake
+	}
+
+	//the rest of the configuration comes from the usual LLM_* variables
+	t.Setenv("LLM_PROVIDER", provider)
+
+	model, err := llm.New(context.Background(), llm.ConfigFromEnv())
+
+	if err != nil {
+		t.Fatalf("provider %s: %v", provider, err)
+	}
+
+	return model
+}
+
+func TestMentorScenarios(t *testing.T) {
+	renderer, err := prompts.NewRenderer(os.Getenv("PROMPT_DIR"), os.Getenv("PROMPT_VERSION"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios, err := LoadScenarios("testdata/scenarios")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scenarios) == 0 {
+		t.Fatal("no scenarios in testdata/scenarios")
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			tasks, err := scenario.TaskModels()
+
+			if err != nil {
+				t.Fatalf("%s: %v", scenario.File, err)
+			}
+
+			relatedTasks, err := scenario.RelatedTaskModels()
+
+			if err != nil {
+				t.Fatalf("%s: %v", scenario.File, err)
+			}
+
+			relatedMessages, err := scenario.RelatedMessageModels()
+
+			if err != nil {
+				t.Fatalf("%s: %v", scenario.File, err)
+			}
+
+			persona := scenario.Persona
+
+			if persona == "" {
+				persona = prompts.DefaultPersona
+			}
+
+			prompt, err := service.MentorPrompt(renderer, persona, service.MentorInput{
+				Tasks:           tasks,
+				Reschedules:     scenario.RescheduleCounts(),
+				Memories:        scenario.MemoryModels(),
+				Summary:         scenario.Summary,
+				RelatedTasks:    relatedTasks,
+				RelatedMessages: relatedMessages,
+				UserMessage:     scenario.Message,
+				Now:             scenario.Now,
+			})
+
+			if err != nil {
+				t.Fatalf("render prompt: %v", err)
+			}
+
+			t.Logf("prompt:\n%s", prompt)
+
+			overdue := service.MentorPromptData(tasks, scenario.Now).Stats.Overdue
+
+			for _, assertion := range scenario.PromptAsserts {
+				if failure := Check(assertion, prompt, overdue); failure != "" {
+					t.Errorf("prompt %s", failure)
+				}
+			}
+
+			history := make([]llm.Message, 0, len(scenario.History))
+
+			for _, turn := range scenario.History {
+				history = append(history, llm.Message{Role: turn.Role, Content: turn.Content})
+			}
+
+			//tools are left out: the harness judges what the mentor says, not what it does
+			reply, err := evalModel(t, scenario).Generate(context.Background(), &llm.Request{History: history, Prompt: prompt})
+
+			if err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+
+			t.Logf("reply:\n%s", reply.Text)
+
+			for _, assertion := range scenario.Asserts {
+				if failure := Check(assertion, reply.Text, overdue); failure != "" {
+					t.Errorf("reply %s", failure)
+				}
+			}
+		})
+	}
+}
@@ -2,11 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/Philip-Machar/clario/internal/middleware"
 	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/repository"
 	"github.com/Philip-Machar/clario/internal/service"
+	"github.com/go-chi/chi/v5"
 )
 
 type AIHandler struct {
@@ -28,7 +34,17 @@ func (h *AIHandler) ChatWithMentor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mentorResponse, err := h.AIService.GetMentorResponse(r.Context(), int(userID), userRequest.Message)
+	threadID, ok := h.resolveThread(w, int(userID), userRequest.ThreadID)
+
+	if !ok {
+		return
+	}
+
+	response, err := h.AIService.GetMentorResponse(r.Context(), int(userID), threadID, userRequest.Message)
+
+	if quotaExceeded(w, err) {
+		return
+	}
 
 	if err != nil {
 		http.Error(w, "Failed to get AI response: "+err.Error(), http.StatusInternalServerError)
@@ -37,5 +53,272 @@ func (h *AIHandler) ChatWithMentor(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(models.ChatResponse{Response: mentorResponse})
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetPendingActions lists destructive mentor actions waiting for the user's confirmation
+func (h *AIHandler) GetPendingActions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	actions, err := h.AIService.ActionRepo.ListPending(int(userID))
+
+	if err != nil {
+		http.Error(w, "Failed to get pending actions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(actions)
+}
+
+func (h *AIHandler) ConfirmAction(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid action id", http.StatusBadRequest)
+		return
+	}
+
+	action, err := h.AIService.ConfirmAction(int(userID), id)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Action not found or already resolved", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to confirm action: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(action)
+}
+
+func (h *AIHandler) CancelAction(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid action id", http.StatusBadRequest)
+		return
+	}
+
+	action, err := h.AIService.CancelAction(int(userID), id)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Action not found or already resolved", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to cancel action: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(action)
+}
+
+// ChatStream relays the mentor reply as Server-Sent Events while the model produces it.
+// Events: "token" with {"text": ...} per chunk, then "done" with the full models.ChatResponse,
+// or "error" with {"error": ...} if the model fails mid-stream.
+func (h *AIHandler) ChatStream(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	var userRequest models.ChatRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&userRequest); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	threadID, ok := h.resolveThread(w, int(userID), userRequest.ThreadID)
+
+	if !ok {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	//once the stream has started the status can no longer be 429
+	if err := h.AIService.Usage.Check(int(userID)); err != nil {
+		if !quotaExceeded(w, err) {
+			http.Error(w, "Failed to check AI usage: "+err.Error(), http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	response, err := h.AIService.StreamMentorResponse(r.Context(), int(userID), threadID, userRequest.Message, func(text string) error {
+		return writeEvent(w, flusher, "token", map[string]string{"text": text})
+	})
+
+	if err != nil {
+		//client disconnected, nobody left to tell
+		if r.Context().Err() != nil {
+			log.Printf("Chat stream for user %d cancelled: %v\n", userID, err)
+			return
+		}
+
+		writeEvent(w, flusher, "error", map[string]string{"error": "Failed to get AI response: " + err.Error()})
+		return
+	}
+
+	writeEvent(w, flusher, "done", response)
+}
+
+// resolveThread finds the thread to chat in, writing the error response itself when there is none
+func (h *AIHandler) resolveThread(w http.ResponseWriter, userID, requested int) (int, bool) {
+	threadID, err := h.AIService.ResolveThread(userID, requested)
+
+	return threadOK(w, threadID, err)
+}
+
+// threadOK writes the error response for a thread that could not be picked
+func threadOK(w http.ResponseWriter, threadID int, err error) (int, bool) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		http.Error(w, "Thread not found", http.StatusNotFound)
+		return 0, false
+	case errors.Is(err, service.ErrThreadArchived):
+		http.Error(w, "Thread is archived, unarchive it to keep chatting", http.StatusConflict)
+		return 0, false
+	case err != nil:
+		http.Error(w, "Failed to resolve chat thread: "+err.Error(), http.StatusInternalServerError)
+		return 0, false
+	}
+
+	return threadID, true
+}
+
+// writeEvent writes a single server-sent event with a JSON payload
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, event string, data any) error {
+	payload, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+
+	flusher.Flush()
+
+	return nil
+}
+
+// GetPersonas lists the mentor personas and which one the user has picked
+func (h *AIHandler) GetPersonas(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	personas, err := h.AIService.Prompts.Personas()
+
+	if err != nil {
+		http.Error(w, "Failed to load personas: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	current, err := h.AIService.UserRepo.GetMentorPersona(int(userID))
+
+	if err != nil {
+		http.Error(w, "Failed to get persona: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"current":  current,
+		"personas": personas,
+		"version":  h.AIService.Prompts.Version,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *AIHandler) SetPersona(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	var payload struct {
+		Persona string `json:"persona"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.AIService.Prompts.HasPersona(payload.Persona) {
+		http.Error(w, "Unknown persona: "+payload.Persona, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.AIService.UserRepo.SetMentorPersona(int(userID), payload.Persona); err != nil {
+		http.Error(w, "Failed to set persona: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]string{"message": "Persona updated", "persona": payload.Persona}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// PreviewPrompt renders the prompt the mentor would send for ?message= in ?thread_id=
+// (defaults to the latest thread, or no history when there is none yet), without
+// calling the model or changing anything
+func (h *AIHandler) PreviewPrompt(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	requested, err := threadIDParam(r)
+
+	if err != nil {
+		http.Error(w, "Invalid thread id", http.StatusBadRequest)
+		return
+	}
+
+	threadID, err := h.AIService.LookupThread(int(userID), requested)
+
+	if _, ok := threadOK(w, threadID, err); !ok {
+		return
+	}
+
+	persona, prompt, err := h.AIService.PreviewPrompt(int(userID), threadID, r.URL.Query().Get("message"))
+
+	if err != nil {
+		http.Error(w, "Failed to render prompt: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"persona":   persona,
+		"version":   h.AIService.Prompts.Version,
+		"thread_id": threadID,
+		"prompt":    prompt,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/repository"
+	"github.com/Philip-Machar/clario/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// BreakdownTask asks the mentor to suggest subtasks for a task, without storing them
+func (h *AIHandler) BreakdownTask(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid task id", http.StatusBadRequest)
+		return
+	}
+
+	breakdown, err := h.AIService.BreakdownTask(r.Context(), int(userID), id)
+
+	if quotaExceeded(w, err) {
+		return
+	}
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to break down task: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(breakdown)
+}
+
+// AcceptBreakdown stores the subtasks the user kept, possibly edited, as children of the task
+func (h *AIHandler) AcceptBreakdown(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid task id", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Subtasks []models.SubtaskSuggestion `json:"subtasks"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	subtasks, err := h.AIService.AcceptBreakdown(int(userID), id, payload.Subtasks)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, service.ErrInvalidSubtasks) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to create subtasks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subtasks)
+}
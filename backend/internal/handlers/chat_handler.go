@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 100
+	defaultThreadLimit  = 50
+	maxThreadLimit      = 100
+)
+
+type ChatHandler struct {
+	Repo *repository.ChatRepository
+}
+
+func NewChatHandler(repo *repository.ChatRepository) *ChatHandler {
+	return &ChatHandler{Repo: repo}
+}
+
+// GetHistory returns one page of the user's conversation, newest first.
+// ?thread_id= narrows it to one thread. Pass the returned next_cursor back
+// as ?cursor= to load older messages.
+func (h *ChatHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	cursor, limit, err := pageParams(r, defaultHistoryLimit, maxHistoryLimit)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//messages are ordered by id alone, newest first
+	beforeID := 0
+
+	if cursor != nil {
+		if cursor.Sort != "id" || !cursor.Desc {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+
+		beforeID = cursor.ID
+	}
+
+	threadID, err := threadIDParam(r)
+
+	if err != nil {
+		http.Error(w, "Invalid thread id", http.StatusBadRequest)
+		return
+	}
+
+	//fetch one extra row to know whether there is another page
+	messages, err := h.Repo.ListHistory(int(userIDFromContext), threadID, beforeID, limit+1)
+
+	if err != nil {
+		http.Error(w, "Failed to get chat history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := models.ChatHistoryResponse{Messages: messages}
+
+	if len(messages) > limit {
+		response.Messages = messages[:limit]
+		response.NextCursor = nextCursor(&repository.Cursor{Sort: "id", Desc: true, ID: messages[limit-1].ID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ClearHistory deletes the whole conversation, or only one thread with ?thread_id=
+func (h *ChatHandler) ClearHistory(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	threadID, err := threadIDParam(r)
+
+	if err != nil {
+		http.Error(w, "Invalid thread id", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.Repo.DeleteHistory(int(userIDFromContext), threadID)
+
+	if err != nil {
+		http.Error(w, "Failed to clear chat history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"message": "Chat history cleared",
+		"deleted": deleted,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *ChatHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid message id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.DeleteMessage(id, int(userIDFromContext))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to delete message: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]string{"message": "Message deleted successfully"}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *ChatHandler) GetThreads(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	includeArchived := r.URL.Query().Get("archived") == "true"
+
+	cursor, limit, err := pageParams(r, defaultThreadLimit, maxThreadLimit)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	threads, next, err := h.Repo.ListThreads(int(userIDFromContext), includeArchived, cursor, limit)
+
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to get threads: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := models.ChatThreadListResponse{Threads: threads, NextCursor: nextCursor(next)}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *ChatHandler) CreateThread(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		Title string `json:"title"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if payload.Title == "" {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return
+	}
+
+	thread := models.ChatThread{UserID: int(userIDFromContext), Title: payload.Title}
+
+	if err := h.Repo.CreateThread(&thread); err != nil {
+		http.Error(w, "Failed to create thread: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(thread)
+}
+
+func (h *ChatHandler) RenameThread(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid thread id", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Title string `json:"title"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if payload.Title == "" {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return
+	}
+
+	thread, err := h.Repo.RenameThread(id, int(userIDFromContext), payload.Title)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Thread not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to rename thread: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(thread)
+}
+
+// ArchiveThread archives a thread, or brings it back with {"archived": false}
+func (h *ChatHandler) ArchiveThread(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid thread id", http.StatusBadRequest)
+		return
+	}
+
+	payload := struct {
+		Archived *bool `json:"archived"`
+	}{}
+
+	//an empty body just archives
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	archived := true
+
+	if payload.Archived != nil {
+		archived = *payload.Archived
+	}
+
+	thread, err := h.Repo.SetThreadArchived(id, int(userIDFromContext), archived)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Thread not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to archive thread: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(thread)
+}
+
+// threadIDParam reads the optional ?thread_id= query parameter, 0 when absent
+func threadIDParam(r *http.Request) (int, error) {
+	param := r.URL.Query().Get("thread_id")
+
+	if param == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(param)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Philip-Machar/clario/internal/llm"
+)
+
+type HealthHandler struct {
+	Model *llm.Resilient
+}
+
+func NewHealthHandler(model *llm.Resilient) *HealthHandler {
+	return &HealthHandler{Model: model}
+}
+
+// Health reports the server as up, and "degraded" while the LLM circuit breaker is not closed.
+// It always answers 200: the API still works, the mentor just falls back to canned replies.
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	breaker := h.Model.Status()
+	status := "ok"
+
+	if breaker.State != llm.BreakerClosed {
+		status = "degraded"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      status,
+		"llm_breaker": breaker,
+	})
+}
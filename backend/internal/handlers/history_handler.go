@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetHistory lists the changes made to a task, oldest first, with who made each of them
+func (h *TaskHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid task id", http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.Repo.History(id, int(userIDFromContext))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to get task history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(events)
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+type MemoryHandler struct {
+	Repo *repository.MemoryRepository
+}
+
+func NewMemoryHandler(repo *repository.MemoryRepository) *MemoryHandler {
+	return &MemoryHandler{Repo: repo}
+}
+
+type memoryPayload struct {
+	Kind    string `json:"kind"`
+	Content string `json:"content"`
+}
+
+// validate trims the payload and reports what is wrong with it, if anything
+func (p *memoryPayload) validate() string {
+	p.Content = strings.TrimSpace(p.Content)
+
+	if p.Kind == "" {
+		p.Kind = "fact"
+	}
+
+	if !models.IsMemoryKind(p.Kind) {
+		return "Kind must be one of: " + strings.Join(models.MemoryKinds, ", ")
+	}
+
+	if p.Content == "" {
+		return "Content is required"
+	}
+
+	return ""
+}
+
+// GetAll lists what the mentor remembers about the user, optionally only one ?kind=
+func (h *MemoryHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	memories, err := h.Repo.List(int(userIDFromContext), r.URL.Query().Get("kind"))
+
+	if err != nil {
+		http.Error(w, "Failed to get memories: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(memories)
+}
+
+func (h *MemoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload memoryPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if problem := payload.validate(); problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	memory := models.MentorMemory{
+		UserID:  int(userIDFromContext),
+		Kind:    payload.Kind,
+		Content: payload.Content,
+		Source:  "user",
+	}
+
+	if err := h.Repo.Create(&memory); err != nil {
+		http.Error(w, "Failed to create memory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(memory)
+}
+
+func (h *MemoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid memory id", http.StatusBadRequest)
+		return
+	}
+
+	var payload memoryPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if problem := payload.validate(); problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	memory := models.MentorMemory{
+		ID:      id,
+		UserID:  int(userIDFromContext),
+		Kind:    payload.Kind,
+		Content: payload.Content,
+	}
+
+	err = h.Repo.Update(&memory)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Memory not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to update memory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(memory)
+}
+
+func (h *MemoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid memory id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.Delete(id, int(userIDFromContext))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Memory not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to delete memory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]string{"message": "Memory deleted successfully"}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxNudgeTimes caps how many times a day the mentor may reach out
+const maxNudgeTimes = 6
+
+type NudgeHandler struct {
+	ChatRepo  *repository.ChatRepository
+	NudgeRepo *repository.NudgeRepository
+}
+
+func NewNudgeHandler(chatRepo *repository.ChatRepository, nudgeRepo *repository.NudgeRepository) *NudgeHandler {
+	return &NudgeHandler{ChatRepo: chatRepo, NudgeRepo: nudgeRepo}
+}
+
+// GetUnread lists the nudges the user has not read yet
+func (h *NudgeHandler) GetUnread(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	nudges, err := h.ChatRepo.UnreadNudges(int(userID))
+
+	if err != nil {
+		http.Error(w, "Failed to get nudges: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(nudges)
+}
+
+// MarkRead marks the nudge {id} as read, or every unread nudge on /nudges/read
+func (h *NudgeHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	id := 0
+
+	if raw := chi.URLParam(r, "id"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+
+		if err != nil {
+			http.Error(w, "Invalid nudge id", http.StatusBadRequest)
+			return
+		}
+
+		id = parsed
+	}
+
+	marked, err := h.ChatRepo.MarkNudgesRead(int(userID), id)
+
+	if err != nil {
+		http.Error(w, "Failed to mark nudges read: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if id != 0 && marked == 0 {
+		http.Error(w, "Nudge not found or already read", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]int64{"marked": marked})
+}
+
+func (h *NudgeHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	settings, err := h.NudgeRepo.GetSettings(int(userID))
+
+	if err != nil {
+		http.Error(w, "Failed to get nudge settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdateSettings sets whether and when (HH:MM, server time) the mentor may nudge the user
+func (h *NudgeHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	var payload struct {
+		Enabled bool     `json:"enabled"`
+		Times   []string `json:"times"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	seen := map[string]bool{}
+	times := []string{}
+
+	for _, value := range payload.Times {
+		parsed, err := time.Parse("15:04", value)
+
+		if err != nil {
+			http.Error(w, "Invalid time "+strconv.Quote(value)+", expected HH:MM", http.StatusBadRequest)
+			return
+		}
+
+		//normalise so "9:05" and "09:05" are the same slot
+		slot := parsed.Format("15:04")
+
+		if !seen[slot] {
+			seen[slot] = true
+			times = append(times, slot)
+		}
+	}
+
+	if len(times) > maxNudgeTimes {
+		http.Error(w, "At most "+strconv.Itoa(maxNudgeTimes)+" nudge times a day", http.StatusBadRequest)
+		return
+	}
+
+	sort.Strings(times)
+
+	settings := models.NudgeSettings{UserID: int(userID), Enabled: payload.Enabled, Times: times}
+
+	if err := h.NudgeRepo.SaveSettings(&settings); err != nil {
+		http.Error(w, "Failed to save nudge settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settings)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Philip-Machar/clario/internal/repository"
+)
+
+// pageParams reads ?limit=, capped at maxLimit, and the opaque ?cursor= of a paginated listing
+func pageParams(r *http.Request, defaultLimit, maxLimit int) (*repository.Cursor, int, error) {
+	limit := defaultLimit
+
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+
+		if err != nil || parsed < 1 {
+			return nil, 0, errors.New("Invalid limit")
+		}
+
+		limit = min(parsed, maxLimit)
+	}
+
+	cursor, err := repository.DecodeCursor(r.URL.Query().Get("cursor"))
+
+	if err != nil {
+		return nil, 0, errors.New("Invalid cursor")
+	}
+
+	return cursor, limit, nil
+}
+
+// nextCursor is the token of the next page, "" on the last one
+func nextCursor(cursor *repository.Cursor) string {
+	if cursor == nil {
+		return ""
+	}
+
+	return cursor.Encode()
+}
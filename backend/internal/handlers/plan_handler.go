@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// PlanToday asks the mentor for a fresh plan of today and returns it
+func (h *AIHandler) PlanToday(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	plan, err := h.AIService.PlanToday(r.Context(), int(userID))
+
+	if quotaExceeded(w, err) {
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to plan the day: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(plan)
+}
+
+// GetTodayPlan returns the latest plan made for today
+func (h *AIHandler) GetTodayPlan(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	plan, err := h.AIService.TodayPlan(int(userID))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "No plan for today yet", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to get plan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(plan)
+}
+
+// AcceptPlan applies the plan's deferrals to the user's tasks
+func (h *AIHandler) AcceptPlan(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid plan id", http.StatusBadRequest)
+		return
+	}
+
+	plan, rescheduled, skipped, err := h.AIService.AcceptPlan(int(userID), id)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Plan not found or already accepted", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to accept plan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"plan":        plan,
+		"rescheduled": rescheduled,
+		"skipped":     skipped, //task ids deleted since, or with a date that does not parse
+	})
+}
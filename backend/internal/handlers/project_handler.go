@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+const maxProjectName = 80
+
+type ProjectHandler struct {
+	Repo *repository.ProjectRepository
+}
+
+func NewProjectHandler(repo *repository.ProjectRepository) *ProjectHandler {
+	return &ProjectHandler{Repo: repo}
+}
+
+// repo records what r does to tasks in their history as made by clientSource
+func (h *ProjectHandler) repo(r *http.Request) *repository.ProjectRepository {
+	return h.Repo.WithSource(clientSource(r))
+}
+
+type projectPayload struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+	Archived    bool   `json:"archived"`
+}
+
+// validate trims the payload and reports what is wrong with it, if anything
+func (p *projectPayload) validate() string {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Description = strings.TrimSpace(p.Description)
+	p.Color = strings.ToLower(strings.TrimSpace(p.Color))
+
+	if p.Name == "" {
+		return "Name is required"
+	}
+
+	if len(p.Name) > maxProjectName {
+		return "Name must be at most " + strconv.Itoa(maxProjectName) + " characters"
+	}
+
+	if p.Color != "" && !tagColorPattern.MatchString(p.Color) {
+		return "Color must look like #rrggbb"
+	}
+
+	return ""
+}
+
+// GetAll lists the user's projects with their stats, ?archived=true includes archived ones
+func (h *ProjectHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	includeArchived := r.URL.Query().Get("archived") == "true"
+
+	projects, err := h.Repo.List(int(userIDFromContext), includeArchived)
+
+	if err != nil {
+		http.Error(w, "Failed to get projects: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(projects)
+}
+
+func (h *ProjectHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid project id", http.StatusBadRequest)
+		return
+	}
+
+	project, err := h.Repo.GetByID(id, int(userIDFromContext))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to get project: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(project)
+}
+
+func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload projectPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if problem := payload.validate(); problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	project := models.Project{
+		UserID:      int(userIDFromContext),
+		Name:        payload.Name,
+		Description: payload.Description,
+		Color:       payload.Color,
+		Archived:    payload.Archived,
+		Stats:       &models.ProjectStats{},
+	}
+
+	if err := h.Repo.Create(&project); err != nil {
+		http.Error(w, "Failed to create project: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(project)
+}
+
+// Update replaces the name, description, color and archive flag of a project
+func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid project id", http.StatusBadRequest)
+		return
+	}
+
+	var payload projectPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if problem := payload.validate(); problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	project := models.Project{
+		ID:          id,
+		UserID:      int(userIDFromContext),
+		Name:        payload.Name,
+		Description: payload.Description,
+		Color:       payload.Color,
+		Archived:    payload.Archived,
+	}
+
+	err = h.Repo.Update(&project)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to update project: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(project)
+}
+
+// Delete removes a project. Its tasks move to the inbox, or to the trash with ?tasks=delete.
+func (h *ProjectHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid project id", http.StatusBadRequest)
+		return
+	}
+
+	mode := r.URL.Query().Get("tasks")
+
+	if mode != "" && mode != "inbox" && mode != "delete" {
+		http.Error(w, "tasks must be inbox or delete", http.StatusBadRequest)
+		return
+	}
+
+	err = h.repo(r).Delete(id, int(userIDFromContext), mode == "delete")
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to delete project: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]string{"message": "Project deleted successfully"}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Reorder sets the order of the user's projects from the full list of their ids
+func (h *ProjectHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		IDs []int `json:"ids"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.Repo.Reorder(int(userIDFromContext), payload.IDs)
+
+	if errors.Is(err, repository.ErrProjectOrder) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to reorder projects: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	projects, err := h.Repo.List(int(userIDFromContext), true)
+
+	if err != nil {
+		http.Error(w, "Failed to get projects: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(projects)
+}
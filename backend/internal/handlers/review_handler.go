@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/repository"
+	"github.com/Philip-Machar/clario/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultReviewLimit = 12
+	maxReviewLimit     = 52
+)
+
+// GetReviews lists the user's weekly reviews, most recent week first. ?limit= defaults to 12.
+func (h *AIHandler) GetReviews(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	limit := defaultReviewLimit
+
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+
+		limit = min(parsed, maxReviewLimit)
+	}
+
+	reviews, err := h.AIService.ReviewRepo.List(int(userID), limit)
+
+	if err != nil {
+		http.Error(w, "Failed to get reviews: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(reviews)
+}
+
+func (h *AIHandler) GetReview(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid review id", http.StatusBadRequest)
+		return
+	}
+
+	review, err := h.AIService.ReviewRepo.GetByID(id, int(userID))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to get review: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(review)
+}
+
+// CreateReview has the mentor review a week, ?week=YYYY-MM-DD being any day in it.
+// It defaults to the current week; reviewing a week again replaces its review.
+func (h *AIHandler) CreateReview(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	day := time.Now()
+
+	if raw := r.URL.Query().Get("week"); raw != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+
+		if err != nil {
+			http.Error(w, "Invalid week, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+
+		day = parsed
+	}
+
+	review, err := h.AIService.WeeklyReview(r.Context(), int(userID), day)
+
+	if quotaExceeded(w, err) {
+		return
+	}
+
+	if errors.Is(err, service.ErrFutureWeek) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to review the week: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(review)
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetSubtasks lists the subtasks of a task in order, with the parent's progress.
+// Subtasks are completed like any task, through PUT /task/{id}/status.
+func (h *TaskHandler) GetSubtasks(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid task id", http.StatusBadRequest)
+		return
+	}
+
+	parent, err := h.Repo.GetByID(id, int(userIDFromContext))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to get task: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	subtasks, err := h.Repo.GetSubtasks(parent.ID, parent.UserID)
+
+	if err != nil {
+		http.Error(w, "Failed to get subtasks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]any{
+		"subtasks": subtasks,
+		"progress": models.Progress(subtasks),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// AddSubtask creates a subtask at the end of a task's checklist
+func (h *TaskHandler) AddSubtask(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid task id", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		Priority    string     `json:"priority"`
+		DueDate     *time.Time `json:"due_date"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if payload.Title == "" {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return
+	}
+
+	parent, err := h.Repo.GetByID(id, int(userIDFromContext))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to get task: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	//subtasks inherit the priority of their parent unless given one
+	if payload.Priority == "" {
+		payload.Priority = parent.Priority
+	}
+
+	subtask := models.Task{
+		UserID:      parent.UserID,
+		ParentID:    &parent.ID,
+		ProjectID:   parent.ProjectID,
+		Title:       payload.Title,
+		Description: payload.Description,
+		Status:      "todo",
+		Priority:    payload.Priority,
+		DueDate:     payload.DueDate,
+	}
+
+	repo := h.repo(r).WithOperation("create")
+
+	if err := repo.Create(&subtask); err != nil {
+		http.Error(w, "Failed to create subtask: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(undoTokenHeader, repo.Operation)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subtask)
+}
+
+// ReorderSubtasks sets the order of a task's checklist from the full list of subtask ids
+func (h *TaskHandler) ReorderSubtasks(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid task id", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		IDs []int `json:"ids"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	repo := h.repo(r).WithOperation("reorder")
+
+	err = repo.ReorderSubtasks(id, int(userIDFromContext), payload.IDs)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repository.ErrSubtaskOrder) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to reorder subtasks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	subtasks, err := h.Repo.GetSubtasks(id, int(userIDFromContext))
+
+	if err != nil {
+		http.Error(w, "Failed to get subtasks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(undoTokenHeader, repo.Operation)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(subtasks)
+}
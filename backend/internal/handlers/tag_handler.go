@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultTagColor = "#6b7280"
+	maxTagName      = 40
+)
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+type TagHandler struct {
+	Repo *repository.TagRepository
+}
+
+func NewTagHandler(repo *repository.TagRepository) *TagHandler {
+	return &TagHandler{Repo: repo}
+}
+
+type tagPayload struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// validate trims the payload and reports what is wrong with it, if anything
+func (p *tagPayload) validate() string {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Color = strings.ToLower(strings.TrimSpace(p.Color))
+
+	if p.Color == "" {
+		p.Color = defaultTagColor
+	}
+
+	if p.Name == "" {
+		return "Name is required"
+	}
+
+	if len(p.Name) > maxTagName {
+		return "Name must be at most " + strconv.Itoa(maxTagName) + " characters"
+	}
+
+	//commas separate tags in ?tag= filters
+	if strings.Contains(p.Name, ",") {
+		return "Name cannot contain commas"
+	}
+
+	if !tagColorPattern.MatchString(p.Color) {
+		return "Color must look like #rrggbb"
+	}
+
+	return ""
+}
+
+// GetAll lists the user's tags with how many tasks carry each
+func (h *TagHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tags, err := h.Repo.List(int(userIDFromContext))
+
+	if err != nil {
+		http.Error(w, "Failed to get tags: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tags)
+}
+
+func (h *TagHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload tagPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if problem := payload.validate(); problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	tag := models.Tag{
+		UserID: int(userIDFromContext),
+		Name:   payload.Name,
+		Color:  payload.Color,
+	}
+
+	err := h.Repo.Create(&tag)
+
+	if errors.Is(err, repository.ErrDuplicateTag) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to create tag: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tag)
+}
+
+func (h *TagHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid tag id", http.StatusBadRequest)
+		return
+	}
+
+	var payload tagPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if problem := payload.validate(); problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	tag := models.Tag{
+		ID:     id,
+		UserID: int(userIDFromContext),
+		Name:   payload.Name,
+		Color:  payload.Color,
+	}
+
+	err = h.Repo.Update(&tag)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repository.ErrDuplicateTag) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to update tag: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tag)
+}
+
+// Delete removes a tag from the user and from all their tasks
+func (h *TagHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid tag id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.Delete(id, int(userIDFromContext))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to delete tag: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]string{"message": "Tag deleted successfully"}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
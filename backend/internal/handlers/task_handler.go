@@ -2,23 +2,49 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Philip-Machar/clario/internal/middleware"
 	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/recurrence"
 	"github.com/Philip-Machar/clario/internal/repository"
 	"github.com/go-chi/chi/v5"
 )
 
+const (
+	defaultTaskLimit = 50
+	maxTaskLimit     = 200
+)
+
 type TaskHandler struct {
-	Repo *repository.TaskRepository
+	Repo       *repository.TaskRepository
+	UndoWindow time.Duration // how long a change can be undone, or an undo redone
+}
+
+func NewTaskHandler(repo *repository.TaskRepository, undoWindow time.Duration) *TaskHandler {
+	return &TaskHandler{Repo: repo, UndoWindow: undoWindow}
+}
+
+// clientSource is who the task history credits with the changes made by r: the web
+// app, which sends X-Client: web, or else another API client
+func clientSource(r *http.Request) string {
+	if r.Header.Get("X-Client") == "web" {
+		return models.SourceUser
+	}
+
+	return models.SourceAPI
 }
 
-func NewTaskHandler(repo *repository.TaskRepository) *TaskHandler {
-	return &TaskHandler{Repo: repo}
+// repo records the changes made by r in the task history as made by clientSource
+func (h *TaskHandler) repo(r *http.Request) *repository.TaskRepository {
+	return h.Repo.WithSource(clientSource(r))
 }
 
 func (h *TaskHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -32,11 +58,15 @@ func (h *TaskHandler) Create(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Creating task for user ID: %d\n", userIDFromContext)
 
 	var payload struct {
-		Title       string     `json:"title"`
-		Description string     `json:"description"`
-		Status      string     `json:"status"`
-		Priority    string     `json:"priority"`
-		DueDate     *time.Time `json:"due_date"`
+		Title        string     `json:"title"`
+		Description  string     `json:"description"`
+		Status       string     `json:"status"`
+		Priority     string     `json:"priority"`
+		DueDate      *time.Time `json:"due_date"`
+		Recurrence   string     `json:"recurrence"`
+		AutoComplete bool       `json:"auto_complete"`
+		TagIDs       []int      `json:"tag_ids"`
+		ProjectID    *int       `json:"project_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
@@ -61,22 +91,46 @@ func (h *TaskHandler) Create(w http.ResponseWriter, r *http.Request) {
 		payload.Priority = "medium"
 	}
 
+	rule, err := normalizeRecurrence(payload.Recurrence)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	task := models.Task{
-		UserID:      int(userIDFromContext),
-		Title:       payload.Title,
-		Description: payload.Description,
-		Status:      payload.Status,
-		Priority:    payload.Priority,
-		DueDate:     payload.DueDate,
+		UserID:       int(userIDFromContext),
+		Title:        payload.Title,
+		Description:  payload.Description,
+		Status:       payload.Status,
+		Priority:     payload.Priority,
+		DueDate:      payload.DueDate,
+		Recurrence:   rule,
+		AutoComplete: payload.AutoComplete,
+		ProjectID:    payload.ProjectID,
+	}
+
+	for _, tagID := range payload.TagIDs {
+		task.Tags = append(task.Tags, models.Tag{ID: tagID})
 	}
 
-	if err := h.Repo.Create(&task); err != nil {
+	repo := h.repo(r).WithOperation("create")
+
+	err = repo.Create(&task)
+
+	if errors.Is(err, repository.ErrUnknownTag) || errors.Is(err, repository.ErrUnknownProject) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
 		log.Printf("ERROR: Failed to create task in repository: %v\n", err)
 		http.Error(w, "Failed to create a task: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	log.Printf("Task created successfully with ID: %d\n", task.ID)
+	w.Header().Set(undoTokenHeader, repo.Operation)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(task)
@@ -90,16 +144,65 @@ func (h *TaskHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tasks, err := h.Repo.GetAll(int(userIDFromContext))
+	//?view=tree nests subtasks under their parents, the default flat list keeps every task at the top
+	view := r.URL.Query().Get("view")
+
+	if view != "" && view != "flat" && view != "tree" {
+		http.Error(w, "view must be flat or tree", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := taskFilter(r)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cursor, limit, err := pageParams(r, defaultTaskLimit, maxTaskLimit)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//the tree view pages through top-level tasks, each comes with all its subtasks
+	filter.RootsOnly = view == "tree"
+
+	tasks, next, err := h.Repo.ListPage(int(userIDFromContext), filter, cursor, limit)
+
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
 
 	if err != nil {
 		http.Error(w, "Failed to get tasks: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if view == "tree" {
+		ids := make([]int, len(tasks))
+
+		for i, task := range tasks {
+			ids[i] = task.ID
+		}
+
+		subtasks, err := h.Repo.ListDescendants(int(userIDFromContext), ids)
+
+		if err != nil {
+			http.Error(w, "Failed to get subtasks: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		tasks = models.TaskTree(append(tasks, subtasks...))
+	}
+
+	response := models.TaskListResponse{Tasks: tasks, NextCursor: nextCursor(next)}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	json.NewEncoder(w).Encode(response)
 }
 
 func (h *TaskHandler) Delete(w http.ResponseWriter, r *http.Request) {
@@ -118,15 +221,24 @@ func (h *TaskHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.Repo.Delete(id, int(userIDFromContext))
+	//deleting moves the task to the trash, see trash_handler.go
+	repo := h.repo(r).WithOperation("delete")
+
+	err = repo.Delete(id, int(userIDFromContext))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
 
 	if err != nil {
 		http.Error(w, "Failed to Delete task: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	response := map[string]string{"message": "Task deleted successfully"}
+	response := map[string]string{"message": "Task moved to trash"}
 
+	w.Header().Set(undoTokenHeader, repo.Operation)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
@@ -150,11 +262,14 @@ func (h *TaskHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var payload struct {
-		Title       string     `json:"title"`
-		Description string     `json:"description"`
-		Status      string     `json:"status"`
-		Priority    string     `json:"priority"`
-		DueDate     *time.Time `json:"due_date"`
+		Title        string     `json:"title"`
+		Description  string     `json:"description"`
+		Status       string     `json:"status"`
+		Priority     string     `json:"priority"`
+		DueDate      *time.Time `json:"due_date"`
+		Recurrence   *string    `json:"recurrence"` //left out keeps the schedule, "" stops it
+		AutoComplete *bool      `json:"auto_complete"`
+		TagIDs       *[]int     `json:"tag_ids"` //left out keeps the tags, [] removes them
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
@@ -162,6 +277,15 @@ func (h *TaskHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var rule string
+
+	if payload.Recurrence != nil {
+		if rule, err = normalizeRecurrence(*payload.Recurrence); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	task := models.Task{
 		UserID:      int(userIDFromContext),
 		ID:          id,
@@ -172,11 +296,32 @@ func (h *TaskHandler) Update(w http.ResponseWriter, r *http.Request) {
 		DueDate:     payload.DueDate,
 	}
 
-	if err := h.Repo.Update(&task); err != nil {
+	repo := h.repo(r).WithOperation("update")
+
+	extra := repository.TaskUpdate{AutoComplete: payload.AutoComplete, TagIDs: payload.TagIDs}
+
+	if payload.Recurrence != nil {
+		extra.Recurrence = &rule
+	}
+
+	err = repo.Update(&task, extra)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repository.ErrUnknownTag) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
 		http.Error(w, "Failed to update task"+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set(undoTokenHeader, repo.Operation)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(task)
@@ -207,13 +352,33 @@ func (h *TaskHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Repo.UpdateStatus(id, payload.Status, int(userIDFromContext)); err != nil {
+	repo := h.repo(r).WithOperation("status")
+
+	change, err := repo.UpdateStatus(id, payload.Status, int(userIDFromContext))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
 		http.Error(w, "Failed to update status: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	response := map[string]string{"message": "Status updated successfully"}
+	response := map[string]any{"message": "Status updated successfully"}
+
+	//completing a recurring task schedules its next occurrence
+	if change.NextOccurrence != nil {
+		response["next_occurrence"] = change.NextOccurrence
+	}
+
+	//an auto-completing parent follows its subtasks
+	if change.Parent != nil {
+		response["parent"] = change.Parent
+	}
 
+	w.Header().Set(undoTokenHeader, repo.Operation)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
@@ -227,7 +392,21 @@ func (h *TaskHandler) GetCurrentStreaks(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	streak, err := h.Repo.GetCurrentStreaks(int(userIDFromContext))
+	seriesID, err := seriesParam(r)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var streak int
+
+	//?series_id= counts on-time occurrences of one recurring task instead of days
+	if seriesID != 0 {
+		streak, err = h.Repo.GetSeriesStreak(int(userIDFromContext), seriesID)
+	} else {
+		streak, err = h.Repo.GetCurrentStreaks(int(userIDFromContext))
+	}
 
 	if err != nil {
 		http.Error(w, "Failed to get streaks: "+err.Error(), http.StatusInternalServerError)
@@ -251,7 +430,20 @@ func (h *TaskHandler) GetMonthlyHeatmap(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	heatmapData, err := h.Repo.GetMonthlyHeatmapData(int(userIDFromContext))
+	seriesID, err := seriesParam(r)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var heatmapData map[string]int
+
+	if seriesID != 0 {
+		heatmapData, err = h.Repo.GetSeriesHeatmapData(int(userIDFromContext), seriesID)
+	} else {
+		heatmapData, err = h.Repo.GetMonthlyHeatmapData(int(userIDFromContext))
+	}
 
 	if err != nil {
 		http.Error(w, "Failed to get heatmap data: "+err.Error(), http.StatusInternalServerError)
@@ -266,3 +458,245 @@ func (h *TaskHandler) GetMonthlyHeatmap(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// MoveToProject moves a task and its subtasks to another project, a null project_id to the inbox
+func (h *TaskHandler) MoveToProject(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid task id", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		ProjectID *int `json:"project_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	task := models.Task{ID: id, UserID: int(userIDFromContext)}
+
+	repo := h.repo(r).WithOperation("move")
+
+	err = repo.MoveToProject(&task, payload.ProjectID)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repository.ErrUnknownProject) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to move task: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(undoTokenHeader, repo.Operation)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
+
+// GetSeries lists the occurrences of a recurring task, oldest first
+func (h *TaskHandler) GetSeries(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	seriesID, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid series id", http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.Repo.GetSeries(int(userIDFromContext), seriesID)
+
+	if err != nil {
+		http.Error(w, "Failed to get series: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(tasks) == 0 {
+		http.Error(w, "Series not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tasks)
+}
+
+// normalizeRecurrence validates an RRULE-style schedule and returns it in the
+// canonical form that is stored, "" means the task does not repeat
+func normalizeRecurrence(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+
+	rule, err := recurrence.Parse(value)
+
+	if err != nil {
+		return "", err
+	}
+
+	return rule.String(), nil
+}
+
+// taskFilter reads the filters of GET /tasks:
+//
+//	?status=todo,in_progress  ?priority=high       lists match any of the values
+//	?due_from=2026-01-01      ?due_to=2026-01-31   whole days, both inclusive
+//	?completed_from=...       ?completed_to=...
+//	?overdue=true             open tasks due before today
+//	?q=gym -cardio            full text over title and description
+//	?tag=health,work          any of the tags, with ?tag_match=all every one of them
+//	?project=4                one project, or ?project=inbox for tasks in none
+//	?sort=-due                created, updated, due, completed, priority, title or relevance, - for descending
+func taskFilter(r *http.Request) (repository.TaskFilter, error) {
+	var filter repository.TaskFilter
+	var err error
+	query := r.URL.Query()
+
+	for _, value := range query["tag"] {
+		filter.Tags = append(filter.Tags, strings.Split(value, ",")...)
+	}
+
+	switch query.Get("tag_match") {
+	case "", "any":
+	case "all":
+		filter.AllTags = true
+	default:
+		return filter, errors.New("tag_match must be any or all")
+	}
+
+	//?project=inbox lists the tasks in no project
+	if project := query.Get("project"); project == "inbox" {
+		filter.Inbox = true
+	} else if project != "" {
+		projectID, err := strconv.Atoi(project)
+
+		if err != nil || projectID < 1 {
+			return filter, errors.New("project must be a project id or inbox")
+		}
+
+		filter.ProjectID = projectID
+	}
+
+	if filter.Statuses, err = listParam(query.Get("status"), "status", models.TaskStatuses); err != nil {
+		return filter, err
+	}
+
+	if filter.Priorities, err = listParam(query.Get("priority"), "priority", models.TaskPriorities); err != nil {
+		return filter, err
+	}
+
+	if filter.DueFrom, filter.DueBefore, err = dayRange(query.Get("due_from"), query.Get("due_to"), "due"); err != nil {
+		return filter, err
+	}
+
+	if filter.CompletedFrom, filter.CompletedBefore, err = dayRange(query.Get("completed_from"), query.Get("completed_to"), "completed"); err != nil {
+		return filter, err
+	}
+
+	if overdue := query.Get("overdue"); overdue != "" {
+		if filter.Overdue, err = strconv.ParseBool(overdue); err != nil {
+			return filter, errors.New("overdue must be true or false")
+		}
+	}
+
+	filter.Query = query.Get("q")
+
+	if sort := query.Get("sort"); sort != "" {
+		filter.Sort, filter.Desc = strings.TrimPrefix(sort, "-"), strings.HasPrefix(sort, "-")
+
+		if !slices.Contains(repository.TaskSorts, filter.Sort) {
+			return filter, errors.New("sort must be one of created, updated, due, completed, priority, title or relevance")
+		}
+	}
+
+	return filter, nil
+}
+
+// listParam splits a comma separated parameter, every value must be one of allowed
+func listParam(value, name string, allowed []string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	values := strings.Split(value, ",")
+
+	for _, v := range values {
+		if !slices.Contains(allowed, v) {
+			return nil, fmt.Errorf("%s must be one of %s", name, strings.Join(allowed, ", "))
+		}
+	}
+
+	return values, nil
+}
+
+// dayRange reads an inclusive range of YYYY-MM-DD days as [from, before)
+func dayRange(from, to, name string) (*time.Time, *time.Time, error) {
+	var start, before *time.Time
+
+	if from != "" {
+		day, err := time.Parse("2006-01-02", from)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s_from must be YYYY-MM-DD", name)
+		}
+
+		start = &day
+	}
+
+	if to != "" {
+		day, err := time.Parse("2006-01-02", to)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s_to must be YYYY-MM-DD", name)
+		}
+
+		next := day.AddDate(0, 0, 1)
+		before = &next
+	}
+
+	if start != nil && before != nil && !start.Before(*before) {
+		return nil, nil, fmt.Errorf("%s_from must not be after %s_to", name, name)
+	}
+
+	return start, before, nil
+}
+
+// seriesParam reads the optional ?series_id= filter, 0 when absent
+func seriesParam(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("series_id")
+
+	if value == "" {
+		return 0, nil
+	}
+
+	seriesID, err := strconv.Atoi(value)
+
+	if err != nil || seriesID < 1 {
+		return 0, errors.New("invalid series_id")
+	}
+
+	return seriesID, nil
+}
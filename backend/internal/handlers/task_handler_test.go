@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/repository"
+)
+
+func day(value string) *time.Time {
+	t, _ := time.Parse("2006-01-02", value)
+
+	return &t
+}
+
+func TestTaskFilter(t *testing.T) {
+	cases := []struct {
+		query string
+		want  repository.TaskFilter
+	}{
+		{"", repository.TaskFilter{}},
+		{"status=todo,in_progress&priority=high", repository.TaskFilter{Statuses: []string{"todo", "in_progress"}, Priorities: []string{"high"}}},
+		{"tag=health,work&tag=home&tag_match=all", repository.TaskFilter{Tags: []string{"health", "work", "home"}, AllTags: true}},
+		{"project=4", repository.TaskFilter{ProjectID: 4}},
+		{"project=inbox", repository.TaskFilter{Inbox: true}},
+		{"due_from=2026-01-01&due_to=2026-01-31", repository.TaskFilter{DueFrom: day("2026-01-01"), DueBefore: day("2026-02-01")}},
+		{"completed_to=2026-03-01", repository.TaskFilter{CompletedBefore: day("2026-03-02")}},
+		{"overdue=true&q=gym+-cardio", repository.TaskFilter{Overdue: true, Query: "gym -cardio"}},
+		{"sort=-due", repository.TaskFilter{Sort: "due", Desc: true}},
+		{"sort=title", repository.TaskFilter{Sort: "title"}},
+	}
+
+	for _, c := range cases {
+		got, err := taskFilter(httptest.NewRequest("GET", "/tasks?"+c.query, nil))
+
+		if err != nil {
+			t.Errorf("%q: %v", c.query, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: expected %+v, got %+v", c.query, c.want, got)
+		}
+	}
+}
+
+func TestTaskFilterInvalid(t *testing.T) {
+	cases := map[string]string{
+		"tag_match=some":                        "tag_match",
+		"project=0":                             "project",
+		"project=work":                          "project",
+		"status=todo,done":                      "status",
+		"priority=urgent":                       "priority",
+		"due_from=01/02/2026":                   "due_from",
+		"due_to=tomorrow":                       "due_to",
+		"due_from=2026-02-01&due_to=2026-01-01": "due_from must not be after due_to",
+		"completed_from=2026-13-01":             "completed_from",
+		"overdue=maybe":                         "overdue",
+		"sort=-size":                            "sort",
+	}
+
+	for query, want := range cases {
+		_, err := taskFilter(httptest.NewRequest("GET", "/tasks?"+query, nil))
+
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected an error about %s, got %v", query, want, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/repository"
+)
+
+// undoTokenHeader carries the token of the operation a create, update, status change
+// or delete made, which POST /undo takes to revert it
+const undoTokenHeader = "X-Undo-Token"
+
+// Undo reverts the most recent task operation, or the one with the given token and
+// every later one, if they were made within the undo window
+func (h *TaskHandler) Undo(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		Token string `json:"token"` //left out undoes the last operation only
+	}
+
+	//the body is optional
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	operations, err := h.repo(r).Undo(int(userIDFromContext), payload.Token, time.Now().Add(-h.UndoWindow))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Nothing to undo", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repository.ErrUndoConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to undo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{"undone": operations})
+}
+
+// Redo makes again the operation undone last, if it was undone within the undo window
+func (h *TaskHandler) Redo(w http.ResponseWriter, r *http.Request) {
+	userIDFromContext, ok := r.Context().Value(middleware.UserIDKey).(int64)
+
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	operation, err := h.repo(r).Redo(int(userIDFromContext), time.Now().Add(-h.UndoWindow))
+
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Nothing to redo", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repository.ErrUndoConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Failed to redo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(undoTokenHeader, operation.Token)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(operation)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/middleware"
+	"github.com/Philip-Machar/clario/internal/service"
+)
+
+type UsageHandler struct {
+	Meter *service.UsageMeter
+}
+
+func NewUsageHandler(meter *service.UsageMeter) *UsageHandler {
+	return &UsageHandler{Meter: meter}
+}
+
+// GetUsage shows the user their AI usage today and this month against the quotas
+func (h *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int64)
+
+	usage, err := h.Meter.Usage(int(userID))
+
+	if err != nil {
+		http.Error(w, "Failed to get usage: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(usage)
+}
+
+// GetReport is the admin view: usage per user over ?from= to ?to= (YYYY-MM-DD, inclusive),
+// this month so far by default
+func (h *UsageHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := now
+
+	var err error
+
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		if from, err = time.ParseInLocation("2006-01-02", raw, time.Local); err != nil {
+			http.Error(w, "Invalid from, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if raw := r.URL.Query().Get("to"); raw != "" {
+		if to, err = time.ParseInLocation("2006-01-02", raw, time.Local); err != nil {
+			http.Error(w, "Invalid to, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	//to is inclusive, the query wants the start of the day after
+	end := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, time.Local)
+
+	if !from.Before(end) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.Meter.Report(from, end)
+
+	if err != nil {
+		http.Error(w, "Failed to get usage report: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report.To = to.Format("2006-01-02")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// quotaExceeded answers 429 if err is a used up quota, and reports whether it did
+func quotaExceeded(w http.ResponseWriter, err error) bool {
+	var quotaErr *service.QuotaError
+
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+
+	retryAfter := int(time.Until(quotaErr.ResetAt).Seconds()) + 1
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":    "AI usage quota exceeded, try again after it resets",
+		"quota":    quotaErr.Quota,
+		"limit":    quotaErr.Limit,
+		"used":     quotaErr.Used,
+		"reset_at": quotaErr.ResetAt,
+	})
+
+	return true
+}
@@ -0,0 +1,75 @@
+// Package jobs runs periodic background work inside the server process.
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is run by the Scheduler on every tick. Jobs must be safe to run again for
+// the same moment: ticks can be late, and several servers may run the same job.
+type Job interface {
+	Name() string
+	Run(ctx context.Context, now time.Time) error
+}
+
+// JobFunc turns a function into a Job
+type JobFunc struct {
+	JobName string
+	Func    func(ctx context.Context, now time.Time) error
+}
+
+func (j JobFunc) Name() string {
+	return j.JobName
+}
+
+func (j JobFunc) Run(ctx context.Context, now time.Time) error {
+	return j.Func(ctx, now)
+}
+
+// Scheduler runs its jobs one after the other, every Interval, until ctx is cancelled
+type Scheduler struct {
+	Interval time.Duration
+	Jobs     []Job
+}
+
+func NewScheduler(interval time.Duration, jobs ...Job) *Scheduler {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	return &Scheduler{Interval: interval, Jobs: jobs}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick runs every job once; a failing or panicking job does not stop the others
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	for _, job := range s.Jobs {
+		func() {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					log.Printf("Job %s panicked: %v\n", job.Name(), recovered)
+				}
+			}()
+
+			if err := job.Run(ctx, now); err != nil {
+				log.Printf("Job %s failed: %v\n", job.Name(), err)
+			}
+		}()
+	}
+}
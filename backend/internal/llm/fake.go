@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Fake is a deterministic backend for local development and tests.
+// It never touches the network: by default it echoes back a short summary of
+// the request (or, when a ResponseSchema is set, the empty document of that schema),
+// and Respond can be set to script any other behaviour.
+type Fake struct {
+	Respond func(req *Request) (*Reply, error)
+}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Generate(ctx context.Context, req *Request) (*Reply, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if f.Respond != nil {
+		return f.Respond(req)
+	}
+
+	if req.ResponseSchema != nil {
+		text, err := json.Marshal(emptyDocument(req.ResponseSchema))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &Reply{Text: string(text), Usage: fakeUsage(req, string(text))}, nil
+	}
+
+	text := fakeText(req)
+
+	return &Reply{Text: text, Usage: fakeUsage(req, text)}, nil
+}
+
+// fakeUsage estimates token counts at roughly four characters a token
+func fakeUsage(req *Request, text string) Usage {
+	prompt := 0
+
+	for _, message := range turns(req) {
+		prompt += len(message.Content)
+	}
+
+	return Usage{PromptTokens: prompt / 4, ResponseTokens: len(text) / 4}
+}
+
+// emptyDocument returns the zero value of schema: objects with their required
+// properties filled in, empty arrays, first enum values
+func emptyDocument(schema *Schema) any {
+	switch schema.Type {
+	case "object":
+		document := map[string]any{}
+
+		for _, name := range schema.Required {
+			if property, ok := schema.Properties[name]; ok {
+				document[name] = emptyDocument(property)
+			}
+		}
+
+		return document
+	case "array":
+		return []any{}
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	}
+
+	if len(schema.Enum) > 0 {
+		return schema.Enum[0]
+	}
+
+	return ""
+}
+
+// Stream emits the reply Generate would give, one word at a time
+func (f *Fake) Stream(ctx context.Context, req *Request, onChunk ChunkFunc) (*Reply, error) {
+	reply, err := f.Generate(ctx, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	words := strings.SplitAfter(reply.Text, " ")
+
+	for _, word := range words {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if err := onChunk(word); err != nil {
+			return nil, err
+		}
+	}
+
+	return reply, nil
+}
+
+// fakeText builds the default reply from the last line of the prompt, which is
+// where the mentor puts the user's message.
+func fakeText(req *Request) string {
+	messages := turns(req)
+
+	if len(messages) == 0 {
+		return "[fake mentor] Nothing to reply to."
+	}
+
+	last := messages[len(messages)-1]
+
+	if len(last.ToolResults) > 0 {
+		return fmt.Sprintf("[fake mentor] Handled %d tool calls.", len(last.ToolResults))
+	}
+
+	prompt := strings.TrimSpace(last.Content)
+	lastLine := prompt
+
+	if i := strings.LastIndex(prompt, "\n"); i >= 0 {
+		lastLine = strings.TrimSpace(prompt[i+1:])
+	}
+
+	return fmt.Sprintf("[fake mentor] %d earlier messages. You said: %s", len(req.History), lastLine)
+}
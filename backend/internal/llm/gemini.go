@@ -0,0 +1,246 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+const defaultGeminiModel = "gemini-2.5-flash"
+
+// Gemini talks to Google's Generative Language API
+type Gemini struct {
+	Client *genai.Client
+	Model  *genai.GenerativeModel
+}
+
+func NewGemini(ctx context.Context, apiKey, model string) (*Gemini, error) {
+	if apiKey == "" {
+		return nil, errors.New("GEMINI_API_KEY not set")
+	}
+
+	if model == "" {
+		model = defaultGeminiModel
+	}
+
+	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Gemini{
+		Client: client,
+		Model:  client.GenerativeModel(model),
+	}, nil
+}
+
+func (g *Gemini) Generate(ctx context.Context, req *Request) (*Reply, error) {
+	model := g.configure(req)
+	chatSession, last := startChat(model, req)
+
+	response, err := chatSession.SendMessage(ctx, last...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reply{Text: geminiText(response), ToolCalls: geminiToolCalls(response), Usage: geminiUsage(response)}, nil
+}
+
+// Stream does not offer tools to the model, streamed replies are text only
+func (g *Gemini) Stream(ctx context.Context, req *Request, onChunk ChunkFunc) (*Reply, error) {
+	chatSession, last := startChat(g.Model, req)
+
+	responses := chatSession.SendMessageStream(ctx, last...)
+
+	text := ""
+	var usage Usage
+
+	for {
+		response, err := responses.Next()
+
+		if err == iterator.Done {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		//every chunk reports the running total, the last one wins
+		if response.UsageMetadata != nil {
+			usage = geminiUsage(response)
+		}
+
+		chunk := geminiText(response)
+
+		if chunk == "" {
+			continue
+		}
+
+		text += chunk
+
+		if err := onChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Reply{Text: text, Usage: usage}, nil
+}
+
+// configure returns a copy of the shared model set up for this request,
+// so concurrent requests never see each other's tools or response schema
+func (g *Gemini) configure(req *Request) *genai.GenerativeModel {
+	model := *g.Model
+
+	if len(req.Tools) > 0 {
+		declarations := make([]*genai.FunctionDeclaration, 0, len(req.Tools))
+
+		for _, tool := range req.Tools {
+			declarations = append(declarations, &genai.FunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  geminiSchema(tool.Parameters),
+			})
+		}
+
+		model.Tools = []*genai.Tool{{FunctionDeclarations: declarations}}
+	}
+
+	if req.ResponseSchema != nil {
+		model.ResponseMIMEType = "application/json"
+		model.ResponseSchema = geminiSchema(req.ResponseSchema)
+	}
+
+	return &model
+}
+
+// startChat opens a chat session with every turn but the last replayed into it.
+// The parts of the last turn are returned for sending.
+func startChat(model *genai.GenerativeModel, req *Request) (*genai.ChatSession, []genai.Part) {
+	chatSession := model.StartChat()
+	messages := turns(req)
+
+	for _, message := range messages {
+		chatSession.History = append(chatSession.History, geminiContent(message))
+	}
+
+	if len(chatSession.History) == 0 {
+		return chatSession, []genai.Part{genai.Text("")}
+	}
+
+	last := chatSession.History[len(chatSession.History)-1]
+	chatSession.History = chatSession.History[:len(chatSession.History)-1]
+
+	return chatSession, last.Parts
+}
+
+func geminiContent(message Message) *genai.Content {
+	role := "user"
+
+	if message.Role == "assistant" {
+		role = "model"
+	}
+
+	var parts []genai.Part
+
+	if message.Content != "" {
+		parts = append(parts, genai.Text(message.Content))
+	}
+
+	for _, call := range message.ToolCalls {
+		parts = append(parts, genai.FunctionCall{Name: call.Name, Args: call.Args})
+	}
+
+	for _, result := range message.ToolResults {
+		parts = append(parts, genai.FunctionResponse{Name: result.Name, Response: result.Result})
+	}
+
+	if len(parts) == 0 {
+		parts = append(parts, genai.Text(""))
+	}
+
+	return &genai.Content{Role: role, Parts: parts}
+}
+
+func geminiSchema(schema *Schema) *genai.Schema {
+	if schema == nil {
+		return nil
+	}
+
+	types := map[string]genai.Type{
+		"object":  genai.TypeObject,
+		"array":   genai.TypeArray,
+		"string":  genai.TypeString,
+		"integer": genai.TypeInteger,
+		"number":  genai.TypeNumber,
+		"boolean": genai.TypeBoolean,
+	}
+
+	converted := &genai.Schema{
+		Type:        types[schema.Type],
+		Description: schema.Description,
+		Enum:        schema.Enum,
+		Required:    schema.Required,
+		Items:       geminiSchema(schema.Items),
+	}
+
+	if len(schema.Properties) > 0 {
+		converted.Properties = make(map[string]*genai.Schema, len(schema.Properties))
+
+		for name, property := range schema.Properties {
+			converted.Properties[name] = geminiSchema(property)
+		}
+	}
+
+	return converted
+}
+
+// geminiText joins the text parts of the first candidate
+func geminiText(response *genai.GenerateContentResponse) string {
+	text := ""
+
+	if len(response.Candidates) == 0 || response.Candidates[0].Content == nil {
+		return text
+	}
+
+	for _, part := range response.Candidates[0].Content.Parts {
+		if t, ok := part.(genai.Text); ok {
+			text += fmt.Sprint(t)
+		}
+	}
+
+	return text
+}
+
+func geminiUsage(response *genai.GenerateContentResponse) Usage {
+	if response.UsageMetadata == nil {
+		return Usage{}
+	}
+
+	return Usage{
+		PromptTokens:   int(response.UsageMetadata.PromptTokenCount),
+		ResponseTokens: int(response.UsageMetadata.CandidatesTokenCount),
+	}
+}
+
+func geminiToolCalls(response *genai.GenerateContentResponse) []ToolCall {
+	if len(response.Candidates) == 0 || response.Candidates[0].Content == nil {
+		return nil
+	}
+
+	var calls []ToolCall
+
+	for _, part := range response.Candidates[0].Content.Parts {
+		if call, ok := part.(genai.FunctionCall); ok {
+			calls = append(calls, ToolCall{Name: call.Name, Args: call.Args})
+		}
+	}
+
+	return calls
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Message is one turn of conversation history handed to a model.
+// Role is "user", "assistant" or "tool", whatever the backend calls them.
+// Assistant turns may carry the tool calls the model made, and "tool" turns
+// carry the results sent back for them.
+type Message struct {
+	Role        string
+	Content     string
+	ToolCalls   []ToolCall
+	ToolResults []ToolResult
+}
+
+// Request is what the mentor sends to a model: the replayed history plus the new prompt.
+// An empty Prompt means the last History message is the turn to answer, which is how
+// tool results are handed back to the model.
+// With ResponseSchema set the model answers with a JSON document in Reply.Text instead of prose.
+type Request struct {
+	History        []Message
+	Prompt         string
+	Tools          []Tool
+	ResponseSchema *Schema
+}
+
+// Reply is what a model sends back. When the model wants to use tools
+// ToolCalls is set and Text is usually empty.
+type Reply struct {
+	Text      string
+	ToolCalls []ToolCall
+	Usage     Usage
+}
+
+// Usage is the token count the provider reported for a call, zero when it reports none
+type Usage struct {
+	PromptTokens   int
+	ResponseTokens int
+}
+
+// ChunkFunc receives each piece of text as a streaming model produces it.
+// Returning an error stops the stream.
+type ChunkFunc func(text string) error
+
+// MentorModel is implemented by every LLM backend the mentor can talk to.
+type MentorModel interface {
+	Generate(ctx context.Context, req *Request) (*Reply, error)
+
+	// Stream is like Generate but hands text to onChunk as it arrives.
+	// The returned Reply holds the fully assembled text.
+	Stream(ctx context.Context, req *Request, onChunk ChunkFunc) (*Reply, error)
+}
+
+// Config selects and configures a backend
+type Config struct {
+	Provider string // gemini, openai or fake
+	APIKey   string
+	BaseURL  string
+	Model    string
+}
+
+// ConfigFromEnv reads the backend configuration from environment variables.
+// LLM_PROVIDER defaults to gemini so existing deployments keep working.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Provider: os.Getenv("LLM_PROVIDER"),
+		Model:    os.Getenv("LLM_MODEL"),
+	}
+
+	if cfg.Provider == "" {
+		cfg.Provider = "gemini"
+	}
+
+	switch cfg.Provider {
+	case "gemini":
+		cfg.APIKey = os.Getenv("GEMINI_API_KEY")
+	case "openai":
+		cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+		cfg.BaseURL = os.Getenv("OPENAI_BASE_URL")
+	}
+
+	return cfg
+}
+
+// New builds the backend selected by cfg.
+func New(ctx context.Context, cfg Config) (MentorModel, error) {
+	switch cfg.Provider {
+	case "gemini":
+		return NewGemini(ctx, cfg.APIKey, cfg.Model)
+	case "openai":
+		return NewOpenAI(cfg.BaseURL, cfg.APIKey, cfg.Model), nil
+	case "fake":
+		return NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,299 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOpenAIBaseURL = "http://localhost:11434/v1"
+	defaultOpenAIModel   = "llama3.2"
+)
+
+// OpenAI talks to any server exposing the OpenAI chat completions API,
+// e.g. llama.cpp's server or Ollama running locally.
+type OpenAI struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	HTTPClient *http.Client
+}
+
+func NewOpenAI(baseURL, apiKey, model string) *OpenAI {
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+
+	//a client Timeout would also cut off a long streamed reply; the request context
+	//bounds the whole call, this only bounds the wait for the server to start answering
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 2 * time.Minute
+
+	return &OpenAI{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		Model:      model,
+		HTTPClient: &http.Client{Transport: transport},
+	}
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string  `json:"name"`
+		Description string  `json:"description,omitempty"`
+		Parameters  *Schema `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+type openAIResponseFormat struct {
+	Type string `json:"type"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	Tools          []openAITool          `json:"tools,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (u *openAIUsage) usage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+
+	return Usage{PromptTokens: u.PromptTokens, ResponseTokens: u.CompletionTokens}
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta openAIMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+func (o *OpenAI) Generate(ctx context.Context, req *Request) (*Reply, error) {
+	resp, err := o.post(ctx, "/chat/completions", o.chatRequest(req, false))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var response openAIChatResponse
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("openai: response has no choices")
+	}
+
+	message := response.Choices[0].Message
+	reply := &Reply{Text: message.Content, Usage: response.Usage.usage()}
+
+	for _, call := range message.ToolCalls {
+		args := map[string]any{}
+
+		if call.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("openai: bad arguments for %s: %w", call.Function.Name, err)
+			}
+		}
+
+		reply.ToolCalls = append(reply.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Args: args})
+	}
+
+	return reply, nil
+}
+
+// Stream reads the server-sent events of a streaming chat completion.
+// Tools are not offered to the model, streamed replies are text only.
+func (o *OpenAI) Stream(ctx context.Context, req *Request, onChunk ChunkFunc) (*Reply, error) {
+	resp, err := o.post(ctx, "/chat/completions", o.chatRequest(req, true))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	text := ""
+	var usage Usage
+	scanner := bufio.NewScanner(resp.Body)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+
+		if !ok {
+			continue
+		}
+
+		data = strings.TrimSpace(data)
+
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("openai: bad stream chunk: %w", err)
+		}
+
+		//with include_usage the last chunk before [DONE] carries the counts
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		text += chunk.Choices[0].Delta.Content
+
+		if err := onChunk(chunk.Choices[0].Delta.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &Reply{Text: text, Usage: usage}, nil
+}
+
+func (o *OpenAI) chatRequest(req *Request, stream bool) openAIChatRequest {
+	body := openAIChatRequest{Model: o.Model, Stream: stream}
+
+	if stream {
+		body.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+
+	for _, message := range turns(req) {
+		//every tool result is its own message in the OpenAI format
+		if len(message.ToolResults) > 0 {
+			for _, result := range message.ToolResults {
+				content, _ := json.Marshal(result.Result)
+				body.Messages = append(body.Messages, openAIMessage{Role: "tool", Content: string(content), ToolCallID: result.CallID})
+			}
+
+			continue
+		}
+
+		converted := openAIMessage{Role: message.Role, Content: message.Content}
+
+		for _, call := range message.ToolCalls {
+			args, _ := json.Marshal(call.Args)
+
+			toolCall := openAIToolCall{ID: call.ID, Type: "function"}
+			toolCall.Function.Name = call.Name
+			toolCall.Function.Arguments = string(args)
+
+			converted.ToolCalls = append(converted.ToolCalls, toolCall)
+		}
+
+		body.Messages = append(body.Messages, converted)
+	}
+
+	//JSON mode is the common denominator of OpenAI-compatible servers, the prompt spells out the shape
+	if req.ResponseSchema != nil {
+		body.ResponseFormat = &openAIResponseFormat{Type: "json_object"}
+	}
+
+	if !stream {
+		for _, tool := range req.Tools {
+			converted := openAITool{Type: "function"}
+			converted.Function.Name = tool.Name
+			converted.Function.Description = tool.Description
+			converted.Function.Parameters = tool.Parameters
+
+			body.Tools = append(body.Tools, converted)
+		}
+	}
+
+	return body
+}
+
+// post sends body as JSON to path. The caller must close the response body.
+func (o *OpenAI) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.BaseURL+path, bytes.NewReader(payload))
+
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	if o.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+
+	resp, err := o.HTTPClient.Do(httpReq)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &StatusError{Provider: "openai", StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(msg))}
+	}
+
+	return resp, nil
+}
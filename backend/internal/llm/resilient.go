@@ -0,0 +1,229 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+)
+
+// Policy configures how Resilient calls its backend
+type Policy struct {
+	Timeout          time.Duration // per attempt
+	MaxRetries       int           // extra attempts after the first, only for retryable errors
+	Backoff          time.Duration // wait before the first retry, doubled for each one after
+	BreakerThreshold int           // consecutive failures that open the breaker
+	BreakerCooldown  time.Duration // how long the breaker stays open
+}
+
+// PolicyFromEnv reads LLM_TIMEOUT, LLM_MAX_RETRIES, LLM_BACKOFF, LLM_BREAKER_THRESHOLD
+// and LLM_BREAKER_COOLDOWN, keeping the defaults for anything unset or invalid.
+func PolicyFromEnv() Policy {
+	policy := Policy{
+		Timeout:          60 * time.Second,
+		MaxRetries:       2,
+		Backoff:          500 * time.Millisecond,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+
+	envDuration("LLM_TIMEOUT", &policy.Timeout)
+	envDuration("LLM_BACKOFF", &policy.Backoff)
+	envDuration("LLM_BREAKER_COOLDOWN", &policy.BreakerCooldown)
+
+	if retries, err := strconv.Atoi(os.Getenv("LLM_MAX_RETRIES")); err == nil && retries >= 0 {
+		policy.MaxRetries = retries
+	}
+
+	if threshold, err := strconv.Atoi(os.Getenv("LLM_BREAKER_THRESHOLD")); err == nil && threshold > 0 {
+		policy.BreakerThreshold = threshold
+	}
+
+	return policy
+}
+
+func envDuration(name string, value *time.Duration) {
+	if parsed, err := time.ParseDuration(os.Getenv(name)); err == nil && parsed > 0 {
+		*value = parsed
+	}
+}
+
+// Resilient wraps a backend with per-attempt timeouts, retries with exponential
+// backoff on retryable errors, and a circuit breaker shared by every call.
+type Resilient struct {
+	Model   MentorModel
+	Policy  Policy
+	Breaker *Breaker
+}
+
+func NewResilient(model MentorModel, policy Policy) *Resilient {
+	return &Resilient{
+		Model:   model,
+		Policy:  policy,
+		Breaker: NewBreaker(policy.BreakerThreshold, policy.BreakerCooldown),
+	}
+}
+
+func (r *Resilient) Generate(ctx context.Context, req *Request) (*Reply, error) {
+	return r.call(ctx, func(ctx context.Context) (*Reply, error) {
+		return r.Model.Generate(ctx, req)
+	}, nil)
+}
+
+// Stream retries only while nothing has reached onChunk yet; once text is out,
+// starting over would repeat it to the reader.
+func (r *Resilient) Stream(ctx context.Context, req *Request, onChunk ChunkFunc) (*Reply, error) {
+	sent := false
+	var chunkErr error
+
+	return r.call(ctx, func(ctx context.Context) (*Reply, error) {
+		return r.Model.Stream(ctx, req, func(text string) error {
+			sent = true
+			chunkErr = onChunk(text)
+			return chunkErr
+		})
+	}, func(err error) bool {
+		//the reader gave up, which says nothing about the provider
+		return sent || (chunkErr != nil && errors.Is(err, chunkErr))
+	})
+}
+
+// call runs attempt under the policy. Only retryable errors count toward opening the
+// breaker. final, when set, marks errors that must be returned as they are: no retry
+// and no effect on the breaker.
+func (r *Resilient) call(ctx context.Context, attempt func(context.Context) (*Reply, error), final func(error) bool) (*Reply, error) {
+	for try := 0; ; try++ {
+		if err := r.Breaker.Allow(); err != nil {
+			return nil, err
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, r.Policy.Timeout)
+		reply, err := attempt(attemptCtx)
+		cancel()
+
+		if err == nil {
+			r.Breaker.Success()
+			return reply, nil
+		}
+
+		//the caller went away, nothing to learn about the provider
+		if ctx.Err() != nil {
+			r.Breaker.Release()
+			return nil, err
+		}
+
+		if final != nil && final(err) {
+			r.Breaker.Release()
+			return nil, err
+		}
+
+		//a bad request is the caller's fault, it must not cut off everyone else
+		if !Retryable(err) {
+			r.Breaker.Release()
+			return nil, err
+		}
+
+		r.Breaker.Failure(err)
+
+		if try >= r.Policy.MaxRetries {
+			return nil, err
+		}
+
+		if err := sleep(ctx, r.backoff(try)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff doubles the wait for every retry, with some jitter so callers spread out
+func (r *Resilient) backoff(try int) time.Duration {
+	wait := r.Policy.Backoff << try
+
+	return wait/2 + rand.N(wait/2+1)
+}
+
+func sleep(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Status reports the breaker state
+func (r *Resilient) Status() BreakerStatus {
+	return r.Breaker.Status()
+}
+
+// StatusError is an HTTP error answer from a backend
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Provider, e.Status, e.Body)
+}
+
+// Retryable reports whether trying again may help: timeouts, dropped connections,
+// rate limits and server side errors. Bad requests and auth failures are not.
+func Retryable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var statusErr *StatusError
+
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+
+	var googleErr *googleapi.Error
+
+	if errors.As(err, &googleErr) {
+		return retryableStatus(googleErr.Code)
+	}
+
+	var apiErr *apierror.APIError
+
+	if errors.As(err, &apiErr) {
+		if apiErr.HTTPCode() > 0 {
+			return retryableStatus(apiErr.HTTPCode())
+		}
+
+		switch apiErr.GRPCStatus().Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Internal, codes.Aborted:
+			return true
+		}
+
+		return false
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr)
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
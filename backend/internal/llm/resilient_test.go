@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func failingModel(code int) *Fake {
+	return &Fake{Respond: func(req *Request) (*Reply, error) {
+		return nil, &StatusError{Provider: "fake", StatusCode: code, Status: http.StatusText(code)}
+	}}
+}
+
+func testPolicy() Policy {
+	return Policy{Timeout: time.Second, MaxRetries: 0, Backoff: time.Millisecond, BreakerThreshold: 2, BreakerCooldown: time.Minute}
+}
+
+func TestBadRequestsDoNotOpenBreaker(t *testing.T) {
+	r := NewResilient(failingModel(http.StatusBadRequest), testPolicy())
+
+	for i := 0; i < 5; i++ {
+		_, err := r.Generate(context.Background(), &Request{Prompt: "hi"})
+
+		var statusErr *StatusError
+
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+			t.Fatalf("call %d: expected the 400 back, got %v", i, err)
+		}
+	}
+
+	status := r.Status()
+
+	if status.State != BreakerClosed || status.ConsecutiveFailures != 0 {
+		t.Fatalf("expected a closed breaker with no failures, got %+v", status)
+	}
+}
+
+func TestServerErrorsOpenBreaker(t *testing.T) {
+	r := NewResilient(failingModel(http.StatusServiceUnavailable), testPolicy())
+
+	for i := 0; i < 2; i++ {
+		r.Generate(context.Background(), &Request{Prompt: "hi"})
+	}
+
+	if _, err := r.Generate(context.Background(), &Request{Prompt: "hi"}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the breaker to be open, got %v", err)
+	}
+}
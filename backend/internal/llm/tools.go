@@ -0,0 +1,45 @@
+package llm
+
+// Schema is the subset of JSON Schema the backends agree on. It describes tool parameters.
+type Schema struct {
+	Type        string             `json:"type"` // object, array, string, integer, number or boolean
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+}
+
+// Tool is a function the model may ask the server to run
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  *Schema
+}
+
+// ToolCall is a model asking for Tool Name to be run with Args.
+// ID is set by backends that match results to calls by id (OpenAI), Gemini matches by name.
+type ToolCall struct {
+	ID   string
+	Name string
+	Args map[string]any
+}
+
+// ToolResult is the outcome of a ToolCall sent back to the model
+type ToolResult struct {
+	CallID string
+	Name   string
+	Result map[string]any
+}
+
+// turns returns the conversation of req as a flat list of messages, with the prompt
+// (if any) appended as the final user turn
+func turns(req *Request) []Message {
+	messages := append([]Message{}, req.History...)
+
+	if req.Prompt != "" {
+		messages = append(messages, Message{Role: "user", Content: req.Prompt})
+	}
+
+	return messages
+}
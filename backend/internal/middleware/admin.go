@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+)
+
+// AdminOnly lets a request through only if isAdmin says the authenticated user is
+// an admin. It must run after AuthMiddleware.
+func AdminOnly(isAdmin func(userID int) (bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(UserIDKey).(int64)
+
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			admin, err := isAdmin(int(userID))
+
+			if err != nil {
+				log.Printf("ERROR: Admin check failed for user %d: %v\n", userID, err)
+				http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+				return
+			}
+
+			if !admin {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
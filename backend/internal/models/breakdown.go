@@ -0,0 +1,15 @@
+package models
+
+// A concrete next step the mentor suggests for a vague task
+type SubtaskSuggestion struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	DueDate     string `json:"due_date,omitempty"` // YYYY-MM-DD
+}
+
+// Suggested split of a task. Nothing is stored until the client accepts it.
+type TaskBreakdown struct {
+	TaskID   int                 `json:"task_id"`
+	Title    string              `json:"title"`
+	Subtasks []SubtaskSuggestion `json:"subtasks"`
+}
@@ -7,17 +7,56 @@ import (
 type ChatMessage struct {
 	ID        int       `json:"id"`
 	UserID    int       `json:"user_id"`
+	ThreadID  int       `json:"thread_id"`
 	Role      string    `json:"role"`
 	Message   string    `json:"message"`
+	Proactive bool      `json:"proactive,omitempty"` // sent by the mentor unprompted, see Nudge
 	CreatedAt time.Time `json:"created_at"`
 }
 
 // Chat request struct for the API
+// ThreadID is optional, without it the user's most recently active thread is used
 type ChatRequest struct {
-	Message string `json:"message"`
+	Message  string `json:"message"`
+	ThreadID int    `json:"thread_id,omitempty"`
 }
 
 // AI response struct for the API
+// Fallback is set when the model could not be reached and Response is a canned reply
 type ChatResponse struct {
-	Response string `json:"response"`
+	Response string         `json:"response"`
+	ThreadID int            `json:"thread_id,omitempty"`
+	Actions  []MentorAction `json:"actions,omitempty"`
+	Fallback bool           `json:"fallback,omitempty"`
+}
+
+// A named conversation with the mentor, e.g. "weekly planning"
+type ChatThread struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	Title     string    `json:"title"`
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Page of chat history for the API, newest message first
+type ChatHistoryResponse struct {
+	Messages   []ChatMessage `json:"messages"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type ChatThreadListResponse struct {
+	Threads    []ChatThread `json:"threads"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// Condensed version of the older messages of a thread.
+// Messages with an id up to SummarizedThroughID are covered by Summary.
+type ChatSummary struct {
+	ThreadID            int       `json:"thread_id"`
+	UserID              int       `json:"-"`
+	Summary             string    `json:"summary"`
+	SummarizedThroughID int       `json:"summarized_through_id"`
+	UpdatedAt           time.Time `json:"updated_at"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Kinds of things the mentor remembers about a user
+var MemoryKinds = []string{"goal", "identity", "excuse", "work_hours", "fact"}
+
+// A durable fact about the user the mentor keeps across conversations,
+// e.g. a stated goal or the hours they prefer to work
+type MentorMemory struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	Kind      string    `json:"kind"`
+	Content   string    `json:"content"`
+	Source    string    `json:"source"` // user or mentor
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func IsMemoryKind(kind string) bool {
+	for _, k := range MemoryKinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Statuses of a MentorAction
+const (
+	ActionDone              = "done"
+	ActionFailed            = "failed"
+	ActionNeedsConfirmation = "needs_confirmation"
+)
+
+// MentorAction is something the mentor did, or wants to do, to the user's tasks during a chat
+type MentorAction struct {
+	Tool            string         `json:"tool"`
+	Args            map[string]any `json:"args"`
+	Status          string         `json:"status"`
+	Summary         string         `json:"summary"`
+	TaskID          int            `json:"task_id,omitempty"`
+	PendingActionID int            `json:"pending_action_id,omitempty"`
+}
+
+// PendingAction is a destructive mentor action waiting for the user to confirm or cancel it
+type PendingAction struct {
+	ID         int            `json:"id"`
+	UserID     int            `json:"-"`
+	Tool       string         `json:"tool"`
+	Args       map[string]any `json:"args"`
+	Summary    string         `json:"summary"`
+	Status     string         `json:"status"` // pending, confirmed or cancelled
+	CreatedAt  time.Time      `json:"created_at"`
+	ResolvedAt *time.Time     `json:"resolved_at,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// When the mentor may reach out on its own. Times are HH:MM in server time.
+type NudgeSettings struct {
+	UserID    int       `json:"-"`
+	Enabled   bool      `json:"enabled"`
+	Times     []string  `json:"times"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// A message the mentor sent without being asked
+type Nudge struct {
+	ID        int        `json:"id"`
+	ThreadID  int        `json:"thread_id"`
+	Message   string     `json:"message"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
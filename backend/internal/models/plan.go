@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// One time-boxed slot of a daily plan
+type PlanItem struct {
+	TaskID          int    `json:"task_id"`
+	Title           string `json:"title"`
+	StartTime       string `json:"start_time"` // HH:MM, local to the user
+	DurationMinutes int    `json:"duration_minutes"`
+	Reason          string `json:"reason,omitempty"`
+}
+
+// A task the plan suggests moving to a later day
+type PlanDeferral struct {
+	TaskID     int    `json:"task_id"`
+	Title      string `json:"title"`
+	NewDueDate string `json:"new_due_date"` // YYYY-MM-DD
+	Reason     string `json:"reason,omitempty"`
+}
+
+// AI generated plan for one day. Accepting it applies the deferrals.
+type DailyPlan struct {
+	ID         int            `json:"id"`
+	UserID     int            `json:"-"`
+	PlanDate   string         `json:"plan_date"`
+	Items      []PlanItem     `json:"items"`
+	Deferrals  []PlanDeferral `json:"deferrals"`
+	Notes      string         `json:"notes"`
+	AcceptedAt *time.Time     `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
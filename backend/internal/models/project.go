@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Named list of tasks. Tasks belong to at most one project, the others are in the inbox.
+type Project struct {
+	ID          int           `json:"id"`
+	UserID      int           `json:"-"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Color       string        `json:"color,omitempty"` // #rrggbb
+	Archived    bool          `json:"archived"`
+	Position    int           `json:"position"`
+	Stats       *ProjectStats `json:"stats,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// Completion of the tasks in a project, subtasks included
+type ProjectStats struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Overdue   int `json:"overdue"`
+	Percent   int `json:"percent"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// What happened to the user's tasks over one week (Monday to Sunday)
+type WeekStats struct {
+	WeekStart       string   `json:"week_start"` // YYYY-MM-DD, a Monday
+	WeekEnd         string   `json:"week_end"`   // last day counted, today for the current week
+	Completed       int      `json:"completed"`
+	CompletedOnTime int      `json:"completed_on_time"`
+	Missed          int      `json:"missed"`
+	CarriedOver     int      `json:"carried_over"`
+	StreakStart     int      `json:"streak_start"`
+	StreakEnd       int      `json:"streak_end"`
+	BusiestDay      string   `json:"busiest_day,omitempty"`
+	CompletedTasks  []string `json:"completed_tasks"`
+	MissedTasks     []string `json:"missed_tasks"`
+	CarriedTasks    []string `json:"carried_tasks"`
+}
+
+// The mentor's reflection on a week. Generating it again replaces it.
+type WeeklyReview struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"-"`
+	WeekStart    string    `json:"week_start"`
+	Stats        WeekStats `json:"stats"`
+	Wins         []string  `json:"wins"`
+	Patterns     []string  `json:"patterns"`
+	SystemChange string    `json:"system_change"`
+	CreatedAt    time.Time `json:"created_at"`
+}
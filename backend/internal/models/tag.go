@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// User defined label grouping tasks by area, e.g. health, work or study
+type Tag struct {
+	ID             int       `json:"id"`
+	UserID         int       `json:"-"`
+	Name           string    `json:"name"`
+	Color          string    `json:"color"` // #rrggbb
+	TaskCount      int       `json:"task_count,omitempty"`
+	CompletedCount int       `json:"completed_count,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
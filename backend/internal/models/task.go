@@ -1,17 +1,149 @@
-// internal/models/task.go
 package models
 
-import "time"
+import (
+	"sort"
+	"time"
+)
+
+// The statuses and priorities a task can have
+var (
+	TaskStatuses   = []string{"todo", "in_progress", "complete"}
+	TaskPriorities = []string{"low", "medium", "high"}
+)
+
+func IsTaskStatus(status string) bool {
+	for _, s := range TaskStatuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func IsTaskPriority(priority string) bool {
+	for _, p := range TaskPriorities {
+		if p == priority {
+			return true
+		}
+	}
+
+	return false
+}
 
 type Task struct {
-	ID          int        `json:"id"`
-	UserID      int        `json:"-"`
-	Title       string     `json:"title"`
-	Description string     `json:"description"`
-	Status      string     `json:"status"`
-	Priority    string     `json:"priority"`
-	DueDate     *time.Time `json:"due_date,omitempty"`
-	CompletedAt *time.Time `json:"completed_at,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID           int           `json:"id"`
+	UserID       int           `json:"-"`
+	ParentID     *int          `json:"parent_id,omitempty"`
+	ProjectID    *int          `json:"project_id,omitempty"` // nil is the inbox
+	Title        string        `json:"title"`
+	Description  string        `json:"description"`
+	Status       string        `json:"status"`
+	Priority     string        `json:"priority"`
+	DueDate      *time.Time    `json:"due_date,omitempty"`
+	CompletedAt  *time.Time    `json:"completed_at,omitempty"`
+	Recurrence   string        `json:"recurrence,omitempty"` // RRULE-style, see package recurrence
+	SeriesID     *int          `json:"series_id,omitempty"`  // shared by all occurrences of a recurring task
+	Position     int           `json:"position"`             // order among its parent's subtasks
+	AutoComplete bool          `json:"auto_complete"`        // complete once all subtasks are
+	Tags         []Tag         `json:"tags,omitempty"`
+	Progress     *TaskProgress `json:"progress,omitempty"`   // only for tasks with subtasks
+	Subtasks     []Task        `json:"subtasks,omitempty"`   // only in the tree view
+	DeletedAt    *time.Time    `json:"deleted_at,omitempty"` // set while in the trash
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// One page of GET /tasks, pass NextCursor back as ?cursor= for the next one
+type TaskListResponse struct {
+	Tasks      []Task `json:"tasks"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// How many of a task's direct subtasks are complete
+type TaskProgress struct {
+	Done    int `json:"done"`
+	Total   int `json:"total"`
+	Percent int `json:"percent"`
+}
+
+// What a status change did besides updating the task itself
+type StatusChange struct {
+	NextOccurrence *Task `json:"next_occurrence,omitempty"` // scheduled by completing a recurring task
+	Parent         *Task `json:"parent,omitempty"`          // auto-completed or reopened with it
+}
+
+// Progress counts the complete tasks among subtasks, nil when there are none
+func Progress(subtasks []Task) *TaskProgress {
+	if len(subtasks) == 0 {
+		return nil
+	}
+
+	progress := TaskProgress{Total: len(subtasks)}
+
+	for _, subtask := range subtasks {
+		if subtask.Status == "complete" {
+			progress.Done++
+		}
+	}
+
+	progress.Percent = progress.Done * 100 / progress.Total
+
+	return &progress
+}
+
+// TaskTree nests subtasks under their parents, in position order. Tasks whose
+// parent is not in the list stay at the top level, in the order given.
+func TaskTree(tasks []Task) []Task {
+	children := childrenByParent(tasks)
+	inList := make(map[int]bool, len(tasks))
+
+	for _, task := range tasks {
+		inList[task.ID] = true
+	}
+
+	var nest func(task Task) Task
+
+	nest = func(task Task) Task {
+		task.Progress = Progress(children[task.ID])
+		task.Subtasks = nil
+
+		for _, child := range children[task.ID] {
+			task.Subtasks = append(task.Subtasks, nest(child))
+		}
+
+		return task
+	}
+
+	tree := []Task{}
+
+	for _, task := range tasks {
+		if task.ParentID == nil || !inList[*task.ParentID] {
+			tree = append(tree, nest(task))
+		}
+	}
+
+	return tree
+}
+
+func childrenByParent(tasks []Task) map[int][]Task {
+	children := map[int][]Task{}
+
+	for _, task := range tasks {
+		if task.ParentID != nil {
+			children[*task.ParentID] = append(children[*task.ParentID], task)
+		}
+	}
+
+	for _, siblings := range children {
+		sort.SliceStable(siblings, func(i, j int) bool {
+			if siblings[i].Position != siblings[j].Position {
+				return siblings[i].Position < siblings[j].Position
+			}
+
+			return siblings[i].ID < siblings[j].ID
+		})
+	}
+
+	return children
 }
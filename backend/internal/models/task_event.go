@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Who made a change to a task
+const (
+	SourceUser   = "user"   // in the web app
+	SourceAPI    = "api"    // by another API client
+	SourceMentor = "mentor" // by the mentor, or by accepting one of its suggestions
+	SourceSystem = "system" // as a consequence of another change, e.g. the next occurrence of a recurring task
+)
+
+// One change to one field of a task. Values are text, nil when the field was empty;
+// the created, deleted and restored events have no old value.
+type TaskEvent struct {
+	ID        int       `json:"id"`
+	TaskID    int       `json:"task_id"`
+	Field     string    `json:"field"`
+	OldValue  *string   `json:"old_value"`
+	NewValue  *string   `json:"new_value"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// A mutation of the user's tasks that can be undone for a while. Its token is
+// returned in the X-Undo-Token header of the request that made it.
+type TaskOperation struct {
+	Token     string     `json:"token"`
+	Kind      string     `json:"kind"`     // create, update, status or delete
+	TaskIDs   []int      `json:"task_ids"` // every task it touched, e.g. a parent auto-completed with its subtask
+	CreatedAt time.Time  `json:"created_at"`
+	UndoneAt  *time.Time `json:"undone_at,omitempty"`
+}
@@ -0,0 +1,40 @@
+package models
+
+// Model calls and tokens used over some period
+type UsageTotals struct {
+	Requests       int `json:"requests"`
+	PromptTokens   int `json:"prompt_tokens"`
+	ResponseTokens int `json:"response_tokens"`
+	TotalTokens    int `json:"total_tokens"`
+}
+
+// Quotas per user, zero means unlimited. Days and months are calendar ones in server time.
+type UsageLimits struct {
+	DailyTokens     int `json:"daily_tokens"`
+	MonthlyTokens   int `json:"monthly_tokens"`
+	DailyRequests   int `json:"daily_requests"`
+	MonthlyRequests int `json:"monthly_requests"`
+}
+
+// What GET /usage shows a user about their own usage
+type UserUsage struct {
+	Today     UsageTotals            `json:"today"`
+	Month     UsageTotals            `json:"month"`
+	ByFeature map[string]UsageTotals `json:"by_feature"` // this month
+	Limits    UsageLimits            `json:"limits"`
+}
+
+// One user's line in the admin usage report
+type UserUsageSummary struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email"`
+	UsageTotals
+}
+
+// Usage of every user over a period, heaviest first
+type UsageReport struct {
+	From  string             `json:"from"`
+	To    string             `json:"to"`
+	Total UsageTotals        `json:"total"`
+	Users []UserUsageSummary `json:"users"`
+}
@@ -0,0 +1,247 @@
+// Package prompts renders the text the mentor sends to the model from versioned
+// text/template files. The templates are compiled into the binary and can be
+// overridden from disk (PROMPT_DIR) so they can be edited without recompiling.
+package prompts
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+const (
+	DefaultVersion = "v1"
+	DefaultPersona = "strict"
+)
+
+var ErrUnknownPersona = errors.New("unknown persona")
+
+// Persona is a mentor personality the user can pick
+type Persona struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type TaskStats struct {
+	DueToday       int
+	CompletedToday int
+	Overdue        int
+}
+
+// TaskLine is a task as the mentor sees it in the prompt
+type TaskLine struct {
+	ID          int
+	Title       string
+	Description string
+	Priority    string
+	Done        bool
+	Due         string
+	Completed   string // date it was completed, for tasks from the past
+	Rescheduled int    // times its due date was pushed back
+}
+
+// MessageLine is an earlier chat message found by retrieval
+type MessageLine struct {
+	Date string
+	Role string
+	Text string
+}
+
+// MentorData is everything the mentor template can use
+type MentorData struct {
+	Today           string
+	Stats           TaskStats
+	TodayTasks      []TaskLine
+	OverdueTasks    []TaskLine
+	RelatedTasks    []TaskLine    // past tasks relevant to the user message
+	RelatedMessages []MessageLine // older chat messages relevant to the user message
+	Memories        []models.MentorMemory
+	Summary         string
+	UserMessage     string
+}
+
+// Renderer loads the templates of one version. Files found under Dir/<version>
+// win over the embedded ones and are re-read on every render, so edits show up
+// straight away.
+type Renderer struct {
+	Dir     string
+	Version string
+}
+
+// NewRenderer checks that every persona of version renders before handing the renderer out
+func NewRenderer(dir, version string) (*Renderer, error) {
+	if version == "" {
+		version = DefaultVersion
+	}
+
+	r := &Renderer{Dir: dir, Version: version}
+
+	personas, err := r.Personas()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(personas) == 0 {
+		return nil, fmt.Errorf("prompts %s: no personas found", version)
+	}
+
+	for _, persona := range personas {
+		if _, err := r.RenderMentor(persona.Name, MentorData{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Personas lists the personas of the version, from disk and embedded, sorted by name
+func (r *Renderer) Personas() ([]Persona, error) {
+	names := map[string]bool{}
+
+	if entries, err := fs.ReadDir(embedded, path.Join("templates", r.Version, "personas")); err == nil {
+		for _, entry := range entries {
+			names[strings.TrimSuffix(entry.Name(), ".tmpl")] = true
+		}
+	}
+
+	if r.Dir != "" {
+		if entries, err := os.ReadDir(filepath.Join(r.Dir, r.Version, "personas")); err == nil {
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".tmpl") {
+					names[strings.TrimSuffix(entry.Name(), ".tmpl")] = true
+				}
+			}
+		}
+	}
+
+	personas := make([]Persona, 0, len(names))
+
+	for name := range names {
+		tmpl, err := r.parse("personas/" + name + ".tmpl")
+
+		if err != nil {
+			return nil, err
+		}
+
+		var description bytes.Buffer
+
+		if err := tmpl.ExecuteTemplate(&description, "description", nil); err != nil {
+			return nil, fmt.Errorf("persona %s: %w", name, err)
+		}
+
+		personas = append(personas, Persona{Name: name, Description: strings.TrimSpace(description.String())})
+	}
+
+	sort.Slice(personas, func(i, j int) bool { return personas[i].Name < personas[j].Name })
+
+	return personas, nil
+}
+
+func (r *Renderer) HasPersona(name string) bool {
+	//persona names end up in file paths
+	if name == "" || strings.ContainsAny(name, `/\.`) {
+		return false
+	}
+
+	_, err := r.read("personas/" + name + ".tmpl")
+
+	return err == nil
+}
+
+// RenderMentor renders the chat prompt in the voice of persona
+func (r *Renderer) RenderMentor(persona string, data MentorData) (string, error) {
+	if !r.HasPersona(persona) {
+		return "", fmt.Errorf("%w %q", ErrUnknownPersona, persona)
+	}
+
+	tmpl, err := r.parse("mentor.tmpl", "personas/"+persona+".tmpl")
+
+	if err != nil {
+		return "", err
+	}
+
+	return execute(tmpl, "mentor.tmpl", data)
+}
+
+// Render renders a standalone template of the version, e.g. "summary.tmpl"
+func (r *Renderer) Render(name string, data any) (string, error) {
+	tmpl, err := r.parse(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	return execute(tmpl, name, data)
+}
+
+func execute(tmpl *template.Template, name string, data any) (string, error) {
+	var out bytes.Buffer
+
+	if err := tmpl.ExecuteTemplate(&out, name, data); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+// parse builds one template set out of files, named by their path inside the version
+func (r *Renderer) parse(files ...string) (*template.Template, error) {
+	var tmpl *template.Template
+
+	for _, file := range files {
+		text, err := r.read(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if tmpl == nil {
+			tmpl = template.New(file).Option("missingkey=error")
+		} else {
+			tmpl = tmpl.New(file)
+		}
+
+		if _, err := tmpl.Parse(text); err != nil {
+			return nil, fmt.Errorf("prompts %s/%s: %w", r.Version, file, err)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// read returns a template file of the version, from Dir if it is there, embedded otherwise
+func (r *Renderer) read(file string) (string, error) {
+	if r.Dir != "" {
+		data, err := os.ReadFile(filepath.Join(r.Dir, r.Version, filepath.FromSlash(file)))
+
+		if err == nil {
+			return string(data), nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	data, err := embedded.ReadFile(path.Join("templates", r.Version, file))
+
+	if err != nil {
+		return "", fmt.Errorf("prompts %s/%s: %w", r.Version, file, err)
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,200 @@
+// Package recurrence parses the RRULE-style schedules of recurring tasks and works
+// out when the next occurrence is due. Only the subset the app offers is supported:
+//
+//	FREQ=DAILY                        every day
+//	FREQ=DAILY;INTERVAL=3             every 3 days
+//	FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR  weekdays
+//	FREQ=WEEKLY;BYDAY=MO,TH           weekly on given days (INTERVAL=2 for every other week)
+//	FREQ=MONTHLY;BYMONTHDAY=15        monthly on day 15, the last day in shorter months
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+)
+
+var dayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Rule is a parsed recurrence
+type Rule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday // WEEKLY only, sorted
+	MonthDay int            // MONTHLY only, 1 to 31
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;BYDAY=MO,WE". Parts may come in any order.
+func Parse(value string) (Rule, error) {
+	rule := Rule{Interval: 1}
+
+	for _, part := range strings.Split(strings.ToUpper(strings.TrimSpace(value)), ";") {
+		if part == "" {
+			continue
+		}
+
+		name, arg, ok := strings.Cut(part, "=")
+
+		if !ok {
+			return rule, fmt.Errorf("%w: %q is not NAME=VALUE", ErrInvalidRule, part)
+		}
+
+		switch name {
+		case "FREQ":
+			rule.Freq = arg
+		case "INTERVAL":
+			interval, err := strconv.Atoi(arg)
+
+			if err != nil || interval < 1 || interval > 365 {
+				return rule, fmt.Errorf("%w: INTERVAL must be between 1 and 365", ErrInvalidRule)
+			}
+
+			rule.Interval = interval
+		case "BYDAY":
+			for _, code := range strings.Split(arg, ",") {
+				day := weekday(code)
+
+				if day < 0 {
+					return rule, fmt.Errorf("%w: unknown day %q, use %s", ErrInvalidRule, code, strings.Join(dayCodes, ","))
+				}
+
+				if !containsDay(rule.ByDay, day) {
+					rule.ByDay = append(rule.ByDay, day)
+				}
+			}
+		case "BYMONTHDAY":
+			day, err := strconv.Atoi(arg)
+
+			if err != nil || day < 1 || day > 31 {
+				return rule, fmt.Errorf("%w: BYMONTHDAY must be between 1 and 31", ErrInvalidRule)
+			}
+
+			rule.MonthDay = day
+		default:
+			return rule, fmt.Errorf("%w: %s is not supported", ErrInvalidRule, name)
+		}
+	}
+
+	switch rule.Freq {
+	case Daily:
+		if len(rule.ByDay) > 0 || rule.MonthDay != 0 {
+			return rule, fmt.Errorf("%w: DAILY takes only INTERVAL", ErrInvalidRule)
+		}
+	case Weekly:
+		if len(rule.ByDay) == 0 || rule.MonthDay != 0 {
+			return rule, fmt.Errorf("%w: WEEKLY needs BYDAY", ErrInvalidRule)
+		}
+	case Monthly:
+		if rule.MonthDay == 0 || len(rule.ByDay) > 0 {
+			return rule, fmt.Errorf("%w: MONTHLY needs BYMONTHDAY", ErrInvalidRule)
+		}
+	case "":
+		return rule, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	default:
+		return rule, fmt.Errorf("%w: FREQ must be DAILY, WEEKLY or MONTHLY", ErrInvalidRule)
+	}
+
+	sort.Slice(rule.ByDay, func(i, j int) bool { return rule.ByDay[i] < rule.ByDay[j] })
+
+	return rule, nil
+}
+
+// String writes the rule back in canonical form, which is what gets stored
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	if len(r.ByDay) > 0 {
+		codes := make([]string, 0, len(r.ByDay))
+
+		for _, day := range r.ByDay {
+			codes = append(codes, dayCodes[day])
+		}
+
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+
+	if r.MonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.MonthDay))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence after the one due at due, keeping its time of day
+func (r Rule) Next(due time.Time) time.Time {
+	switch r.Freq {
+	case Daily:
+		return due.AddDate(0, 0, r.Interval)
+	case Weekly:
+		anchor := startOfWeek(due)
+
+		for day := due.AddDate(0, 0, 1); ; day = day.AddDate(0, 0, 1) {
+			weeks := int(startOfWeek(day).Sub(anchor).Hours()/24+0.5) / 7
+
+			if weeks%r.Interval == 0 && containsDay(r.ByDay, day.Weekday()) {
+				return day
+			}
+		}
+	default:
+		year, month, _ := due.Date()
+		first := time.Date(year, month+time.Month(r.Interval), 1, due.Hour(), due.Minute(), due.Second(), 0, due.Location())
+
+		//day 31 falls back to the last day of shorter months
+		lastDay := first.AddDate(0, 1, -1).Day()
+
+		return first.AddDate(0, 0, min(r.MonthDay, lastDay)-1)
+	}
+}
+
+// NextOnOrAfter is Next, skipping occurrences that are already in the past at from.
+// Completing an old occurrence late then schedules the next one from today on.
+func (r Rule) NextOnOrAfter(due, from time.Time) time.Time {
+	next := r.Next(due)
+	fromDate := from.Format("2006-01-02")
+
+	for next.Format("2006-01-02") < fromDate {
+		next = r.Next(next)
+	}
+
+	return next
+}
+
+func weekday(code string) time.Weekday {
+	for i, dayCode := range dayCodes {
+		if code == dayCode {
+			return time.Weekday(i)
+		}
+	}
+
+	return -1
+}
+
+func containsDay(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// startOfWeek returns the Monday of t's week, at t's time of day
+func startOfWeek(t time.Time) time.Time {
+	return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
@@ -0,0 +1,127 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(value string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", value)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func TestParse(t *testing.T) {
+	cases := map[string]string{
+		"FREQ=DAILY":                           "FREQ=DAILY",
+		"FREQ=DAILY;INTERVAL=1":                "FREQ=DAILY",
+		"freq=daily;interval=3":                "FREQ=DAILY;INTERVAL=3",
+		"BYDAY=WE,MO;FREQ=WEEKLY":              "FREQ=WEEKLY;BYDAY=MO,WE",
+		"FREQ=WEEKLY;BYDAY=MO,MO,SU":           "FREQ=WEEKLY;BYDAY=SU,MO",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=TH":      "FREQ=WEEKLY;INTERVAL=2;BYDAY=TH",
+		" FREQ=MONTHLY;BYMONTHDAY=31; ":        "FREQ=MONTHLY;BYMONTHDAY=31",
+		"FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=1": "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=1",
+	}
+
+	for value, want := range cases {
+		rule, err := Parse(value)
+
+		if err != nil {
+			t.Errorf("%q: %v", value, err)
+			continue
+		}
+
+		if got := rule.String(); got != want {
+			t.Errorf("%q: expected %q, got %q", value, want, got)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"FREQ",
+		"FREQ=YEARLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=366",
+		"FREQ=DAILY;INTERVAL=x",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=MO;BYMONTHDAY=1",
+		"FREQ=MONTHLY",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;COUNT=5",
+	}
+
+	for _, value := range invalid {
+		if _, err := Parse(value); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("%q: expected ErrInvalidRule, got %v", value, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	cases := []struct {
+		name, rule, due, want string
+	}{
+		{"daily", "FREQ=DAILY", "2026-01-31 09:30", "2026-02-01 09:30"},
+		{"every 3 days across a month", "FREQ=DAILY;INTERVAL=3", "2026-02-27 08:00", "2026-03-02 08:00"},
+		{"daily into a leap day", "FREQ=DAILY", "2028-02-28 08:00", "2028-02-29 08:00"},
+		{"weekdays from friday", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", "2026-01-09 07:00", "2026-01-12 07:00"},
+		{"same week", "FREQ=WEEKLY;BYDAY=MO,TH", "2026-01-05 18:00", "2026-01-08 18:00"},
+		{"every other week skips one", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", "2026-01-08 18:00", "2026-01-19 18:00"},
+		{"weekly on sunday", "FREQ=WEEKLY;BYDAY=SU", "2026-01-04 10:00", "2026-01-11 10:00"},
+		{"monthly", "FREQ=MONTHLY;BYMONTHDAY=15", "2026-01-15 12:00", "2026-02-15 12:00"},
+		{"month end into february", "FREQ=MONTHLY;BYMONTHDAY=31", "2026-01-31 12:00", "2026-02-28 12:00"},
+		{"month end back to 31", "FREQ=MONTHLY;BYMONTHDAY=31", "2026-02-28 12:00", "2026-03-31 12:00"},
+		{"month end into april", "FREQ=MONTHLY;BYMONTHDAY=31", "2026-03-31 12:00", "2026-04-30 12:00"},
+		{"leap day", "FREQ=MONTHLY;BYMONTHDAY=29", "2028-01-29 12:00", "2028-02-29 12:00"},
+		{"no leap day", "FREQ=MONTHLY;BYMONTHDAY=29", "2027-01-29 12:00", "2027-02-28 12:00"},
+		{"quarterly across the year", "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=30", "2026-11-30 12:00", "2027-02-28 12:00"},
+	}
+
+	for _, c := range cases {
+		rule, err := Parse(c.rule)
+
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		if got := rule.Next(date(c.due)); !got.Equal(date(c.want)) {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, got.Format("2006-01-02 15:04"))
+		}
+	}
+}
+
+func TestNextOnOrAfter(t *testing.T) {
+	cases := []struct {
+		name, rule, due, from, want string
+	}{
+		{"on time", "FREQ=DAILY", "2026-01-01 09:00", "2026-01-01 20:00", "2026-01-02 09:00"},
+		{"late lands on today", "FREQ=DAILY;INTERVAL=3", "2026-01-01 09:00", "2026-01-10 20:00", "2026-01-10 09:00"},
+		{"late keeps the interval", "FREQ=DAILY;INTERVAL=3", "2026-01-01 09:00", "2026-01-11 08:00", "2026-01-13 09:00"},
+		{"late weekly", "FREQ=WEEKLY;BYDAY=MO", "2026-01-05 09:00", "2026-01-21 09:00", "2026-01-26 09:00"},
+		{"late monthly at month end", "FREQ=MONTHLY;BYMONTHDAY=31", "2026-01-31 09:00", "2026-03-01 09:00", "2026-03-31 09:00"},
+		{"early keeps the schedule", "FREQ=WEEKLY;BYDAY=FR", "2026-01-16 09:00", "2026-01-12 09:00", "2026-01-23 09:00"},
+	}
+
+	for _, c := range cases {
+		rule, err := Parse(c.rule)
+
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		if got := rule.NextOnOrAfter(date(c.due), date(c.from)); !got.Equal(date(c.want)) {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, got.Format("2006-01-02 15:04"))
+		}
+	}
+}
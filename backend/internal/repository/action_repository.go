@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+type ActionRepository struct {
+	DB *sql.DB
+}
+
+func NewActionRepository(db *sql.DB) *ActionRepository {
+	return &ActionRepository{DB: db}
+}
+
+const pendingActionColumns = `id, user_id, tool, args, summary, status, created_at, resolved_at`
+
+func (r *ActionRepository) Create(action *models.PendingAction) error {
+	args, err := json.Marshal(action.Args)
+
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO ai_pending_actions (user_id, tool, args, summary)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, status, created_at
+	`
+
+	return r.DB.QueryRow(query, action.UserID, action.Tool, args, action.Summary).
+		Scan(&action.ID, &action.Status, &action.CreatedAt)
+}
+
+// ListPending returns the actions still waiting for the user, oldest first
+func (r *ActionRepository) ListPending(userID int) ([]models.PendingAction, error) {
+	query := `SELECT ` + pendingActionColumns + ` FROM ai_pending_actions WHERE user_id = $1 AND status = 'pending' ORDER BY id ASC`
+
+	rows, err := r.DB.Query(query, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	actions := []models.PendingAction{}
+
+	for rows.Next() {
+		action, err := scanPendingAction(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		actions = append(actions, *action)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return actions, nil
+}
+
+// Resolve moves a pending action to status (confirmed or cancelled). It only succeeds once:
+// an action that is missing, owned by someone else or already resolved gives ErrNotFound.
+func (r *ActionRepository) Resolve(id, userID int, status string) (*models.PendingAction, error) {
+	query := `
+		UPDATE ai_pending_actions SET status = $1, resolved_at = NOW()
+		WHERE id = $2 AND user_id = $3 AND status = 'pending'
+		RETURNING ` + pendingActionColumns
+
+	action, err := scanPendingAction(r.DB.QueryRow(query, status, id, userID))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	return action, err
+}
+
+// Reopen puts a confirmed action back to pending, for when carrying it out failed
+func (r *ActionRepository) Reopen(id, userID int) error {
+	query := `UPDATE ai_pending_actions SET status = 'pending', resolved_at = NULL WHERE id = $1 AND user_id = $2 AND status = 'confirmed'`
+
+	_, err := r.DB.Exec(query, id, userID)
+
+	return err
+}
+
+func scanPendingAction(row rowScanner) (*models.PendingAction, error) {
+	var action models.PendingAction
+	var args []byte
+	var resolved sql.NullTime
+
+	if err := row.Scan(&action.ID, &action.UserID, &action.Tool, &args, &action.Summary, &action.Status, &action.CreatedAt, &resolved); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(args, &action.Args); err != nil {
+		return nil, err
+	}
+
+	if resolved.Valid {
+		action.ResolvedAt = &resolved.Time
+	}
+
+	return &action, nil
+}
@@ -14,18 +14,104 @@ func NewChatRepository(db *sql.DB) *ChatRepository {
 	return &ChatRepository{DB: db}
 }
 
-func (r *ChatRepository) SaveMessage(UserID int, role string, message string) error {
-	query := `INSERT INTO ai_chats (user_id, role, message) VALUES ($1, $2, $3)`
+// SaveMessage appends a message to a thread and bumps the thread so it sorts as most recently active
+func (r *ChatRepository) SaveMessage(UserID int, threadID int, role string, message string) error {
+	query := `INSERT INTO ai_chats (user_id, thread_id, role, message) VALUES ($1, $2, $3, $4)`
 
-	_, err := r.DB.Exec(query, UserID, role, message)
+	if _, err := r.DB.Exec(query, UserID, threadID, role, message); err != nil {
+		return err
+	}
+
+	_, err := r.DB.Exec(`UPDATE chat_threads SET updated_at = NOW() WHERE id = $1 AND user_id = $2`, threadID, UserID)
 
 	return err
 }
 
-func (r *ChatRepository) GetRecentHistory(UserID int) ([]models.ChatMessage, error) {
-	query := `SELECT role, message FROM ai_chats WHERE id = $1 ORDERED BY created_at ASC LIMIT 20`
+// SaveNudge stores an unread proactive assistant message in a thread and returns its id
+func (r *ChatRepository) SaveNudge(userID, threadID int, message string) (int, error) {
+	query := `INSERT INTO ai_chats (user_id, thread_id, role, message, proactive) VALUES ($1, $2, 'assistant', $3, TRUE) RETURNING id`
+
+	var id int
+
+	if err := r.DB.QueryRow(query, userID, threadID, message).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	_, err := r.DB.Exec(`UPDATE chat_threads SET updated_at = NOW() WHERE id = $1 AND user_id = $2`, threadID, userID)
+
+	return id, err
+}
+
+// UnreadNudges returns the proactive messages the user has not read yet, oldest first
+func (r *ChatRepository) UnreadNudges(userID int) ([]models.Nudge, error) {
+	query := `
+		SELECT id, thread_id, message, created_at FROM ai_chats
+		WHERE user_id = $1 AND proactive AND read_at IS NULL
+		ORDER BY id ASC
+	`
+
+	rows, err := r.DB.Query(query, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	nudges := []models.Nudge{}
+
+	for rows.Next() {
+		var nudge models.Nudge
+
+		if err := rows.Scan(&nudge.ID, &nudge.ThreadID, &nudge.Message, &nudge.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		nudges = append(nudges, nudge)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nudges, nil
+}
+
+// MarkNudgesRead marks one unread nudge as read, or all of them when id is 0, and
+// returns how many were marked
+func (r *ChatRepository) MarkNudgesRead(userID, id int) (int64, error) {
+	query := `
+		UPDATE ai_chats SET read_at = NOW()
+		WHERE user_id = $1 AND ($2 = 0 OR id = $2) AND proactive AND read_at IS NULL
+	`
+
+	result, err := r.DB.Exec(query, userID, id)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// maxReplayMessages bounds how many raw messages are loaded for the mentor,
+// the token budget usually keeps far fewer than this
+const maxReplayMessages = 200
 
-	rows, err := r.DB.Query(query, UserID)
+// GetRecentHistory returns the messages of a thread newer than afterID, oldest first,
+// for replaying to the model. Messages up to afterID are covered by the thread summary.
+func (r *ChatRepository) GetRecentHistory(UserID int, threadID int, afterID int) ([]models.ChatMessage, error) {
+	query := `
+		SELECT id, role, message FROM (
+			SELECT id, role, message FROM ai_chats
+			WHERE user_id = $1 AND thread_id = $2 AND id > $3
+			ORDER BY id DESC
+			LIMIT $4
+		) recent
+		ORDER BY id ASC
+	`
+
+	rows, err := r.DB.Query(query, UserID, threadID, afterID, maxReplayMessages)
 
 	if err != nil {
 		return nil, err
@@ -38,12 +124,200 @@ func (r *ChatRepository) GetRecentHistory(UserID int) ([]models.ChatMessage, err
 	for rows.Next() {
 		var message models.ChatMessage
 
-		if err := rows.Scan(&message.Role, &message.Message); err != nil {
+		if err := rows.Scan(&message.ID, &message.Role, &message.Message); err != nil {
 			return nil, err
 		}
 
 		history = append(history, message)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return history, nil
 }
+
+// ListHistory returns up to limit messages of a user, newest first.
+// threadID narrows the listing to one thread, 0 means every thread.
+// If beforeID is non zero only messages older than it are returned, which is how the
+// handler pages backwards through a conversation.
+func (r *ChatRepository) ListHistory(userID, threadID, beforeID, limit int) ([]models.ChatMessage, error) {
+	query := `
+		SELECT id, user_id, thread_id, role, message, proactive, created_at
+		FROM ai_chats
+		WHERE user_id = $1 AND ($2 = 0 OR thread_id = $2) AND ($3 = 0 OR id < $3)
+		ORDER BY id DESC
+		LIMIT $4
+	`
+
+	rows, err := r.DB.Query(query, userID, threadID, beforeID, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	messages := []models.ChatMessage{}
+
+	for rows.Next() {
+		var message models.ChatMessage
+
+		if err := rows.Scan(&message.ID, &message.UserID, &message.ThreadID, &message.Role, &message.Message, &message.Proactive, &message.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// SearchMessages returns the user's chat messages matching a to_tsquery expression,
+// best match first, across all threads
+func (r *ChatRepository) SearchMessages(userID int, tsquery string, limit int) ([]models.ChatMessage, error) {
+	query := `
+		SELECT id, user_id, thread_id, role, message, proactive, created_at FROM ai_chats
+		WHERE user_id = $1 AND search_vector @@ to_tsquery('english', $2)
+		ORDER BY ts_rank(search_vector, to_tsquery('english', $2)) DESC, id DESC
+		LIMIT $3
+	`
+
+	rows, err := r.DB.Query(query, userID, tsquery, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	messages := []models.ChatMessage{}
+
+	for rows.Next() {
+		var message models.ChatMessage
+
+		if err := rows.Scan(&message.ID, &message.UserID, &message.ThreadID, &message.Role, &message.Message, &message.Proactive, &message.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// DeleteHistory wipes the messages of a user (of one thread, or all of them when threadID is 0)
+// and returns how many were removed. Their summaries go with them, so the mentor forgets them too.
+func (r *ChatRepository) DeleteHistory(userID, threadID int) (int64, error) {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM ai_chats WHERE user_id = $1 AND ($2 = 0 OR thread_id = $2)`, userID, threadID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM chat_summaries WHERE user_id = $1 AND ($2 = 0 OR thread_id = $2)`, userID, threadID); err != nil {
+		return 0, err
+	}
+
+	return deleted, tx.Commit()
+}
+
+// DeleteMessage deletes one message. A summary already covering it is dropped with it,
+// so the message stops reaching the model; the summarizer rebuilds it from what is left.
+func (r *ChatRepository) DeleteMessage(id, userID int) error {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var threadID sql.NullInt64
+
+	err = tx.QueryRow(`DELETE FROM ai_chats WHERE id = $1 AND user_id = $2 RETURNING thread_id`, id, userID).Scan(&threadID)
+
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if threadID.Valid {
+		query := `DELETE FROM chat_summaries WHERE thread_id = $1 AND user_id = $2 AND summarized_through_id >= $3`
+
+		if _, err := tx.Exec(query, threadID.Int64, userID, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *ChatRepository) GetSummary(userID, threadID int) (*models.ChatSummary, error) {
+	query := `
+		SELECT thread_id, user_id, summary, summarized_through_id, updated_at
+		FROM chat_summaries
+		WHERE thread_id = $1 AND user_id = $2
+	`
+
+	var summary models.ChatSummary
+
+	err := r.DB.QueryRow(query, threadID, userID).
+		Scan(&summary.ThreadID, &summary.UserID, &summary.Summary, &summary.SummarizedThroughID, &summary.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+// SaveSummary creates or replaces the summary of a thread. A summary that covers fewer
+// messages than the stored one (a slower concurrent run) is silently dropped.
+func (r *ChatRepository) SaveSummary(summary *models.ChatSummary) error {
+	query := `
+		INSERT INTO chat_summaries (thread_id, user_id, summary, summarized_through_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (thread_id) DO UPDATE
+		SET summary = EXCLUDED.summary, summarized_through_id = EXCLUDED.summarized_through_id, updated_at = NOW()
+		WHERE chat_summaries.summarized_through_id < EXCLUDED.summarized_through_id
+		RETURNING updated_at
+	`
+
+	err := r.DB.QueryRow(query, summary.ThreadID, summary.UserID, summary.Summary, summary.SummarizedThroughID).
+		Scan(&summary.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil
+	}
+
+	return err
+}
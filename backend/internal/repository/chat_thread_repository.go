@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+const threadColumns = `id, user_id, title, archived, created_at, updated_at`
+
+func (r *ChatRepository) CreateThread(thread *models.ChatThread) error {
+	query := `INSERT INTO chat_threads (user_id, title) VALUES ($1, $2) RETURNING id, archived, created_at, updated_at`
+
+	return r.DB.QueryRow(query, thread.UserID, thread.Title).
+		Scan(&thread.ID, &thread.Archived, &thread.CreatedAt, &thread.UpdatedAt)
+}
+
+func (r *ChatRepository) GetThread(id, userID int) (*models.ChatThread, error) {
+	query := `SELECT ` + threadColumns + ` FROM chat_threads WHERE id = $1 AND user_id = $2`
+
+	return scanThread(r.DB.QueryRow(query, id, userID))
+}
+
+// GetLatestThread returns the most recently active thread that is not archived
+func (r *ChatRepository) GetLatestThread(userID int) (*models.ChatThread, error) {
+	query := `
+		SELECT ` + threadColumns + ` FROM chat_threads
+		WHERE user_id = $1 AND archived = FALSE
+		ORDER BY updated_at DESC, id DESC
+		LIMIT 1
+	`
+
+	return scanThread(r.DB.QueryRow(query, userID))
+}
+
+// ListThreads returns up to limit threads of a user, newest first, starting after
+// cursor (nil for the first page), and the cursor of the next page. The order is by
+// creation, which never changes: by last activity, a thread written to while the
+// user pages through would jump pages and push the others across the cursor.
+func (r *ChatRepository) ListThreads(userID int, includeArchived bool, cursor *Cursor, limit int) ([]models.ChatThread, *Cursor, error) {
+	args := []any{userID, includeArchived}
+
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	query := `SELECT ` + threadColumns + `, created_at::text FROM chat_threads WHERE user_id = $1 AND ($2 OR archived = FALSE)`
+
+	if cursor != nil {
+		if cursor.Sort != "created" || !cursor.Desc || cursor.Value == nil || !cursor.valid("timestamp") {
+			return nil, nil, ErrInvalidCursor
+		}
+
+		query += ` AND ` + cursor.after("created_at", "timestamp", arg)
+	}
+
+	//one extra row tells whether there is another page
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ` + arg(limit+1)
+
+	rows, err := r.DB.Query(query, args...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	threads := []models.ChatThread{}
+	var keys []string
+
+	for rows.Next() {
+		var thread models.ChatThread
+		var key string
+
+		if err := rows.Scan(&thread.ID, &thread.UserID, &thread.Title, &thread.Archived, &thread.CreatedAt, &thread.UpdatedAt, &key); err != nil {
+			return nil, nil, err
+		}
+
+		threads = append(threads, thread)
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+
+	if len(threads) > limit {
+		threads = threads[:limit]
+		next = &Cursor{Sort: "created", Desc: true, Value: &keys[limit-1], ID: threads[limit-1].ID}
+	}
+
+	return threads, next, nil
+}
+
+func (r *ChatRepository) RenameThread(id, userID int, title string) (*models.ChatThread, error) {
+	query := `
+		UPDATE chat_threads SET title = $1, updated_at = NOW()
+		WHERE id = $2 AND user_id = $3
+		RETURNING ` + threadColumns
+
+	return scanThread(r.DB.QueryRow(query, title, id, userID))
+}
+
+func (r *ChatRepository) SetThreadArchived(id, userID int, archived bool) (*models.ChatThread, error) {
+	query := `
+		UPDATE chat_threads SET archived = $1
+		WHERE id = $2 AND user_id = $3
+		RETURNING ` + threadColumns
+
+	return scanThread(r.DB.QueryRow(query, archived, id, userID))
+}
+
+func scanThread(row *sql.Row) (*models.ChatThread, error) {
+	var thread models.ChatThread
+
+	err := row.Scan(&thread.ID, &thread.UserID, &thread.Title, &thread.Archived, &thread.CreatedAt, &thread.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &thread, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a cursor that was tampered with or belongs to another listing
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks where a page of a keyset paginated listing ended: the sort key
+// and id of its last row. Clients only ever see it encoded, as an opaque token.
+type Cursor struct {
+	Sort  string  `json:"s"`
+	Desc  bool    `json:"d,omitempty"`
+	Value *string `json:"v,omitempty"` // sort key as Postgres text, nil for NULL
+	ID    int     `json:"i"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads a token made by Encode, "" is no cursor
+func DecodeCursor(token string) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// valid reports whether the sort key of c reads as cast, so a token edited by
+// the client is refused instead of failing the query
+func (c *Cursor) valid(cast string) bool {
+	if c.Value == nil {
+		return true
+	}
+
+	var err error
+
+	switch cast {
+	case "timestamp":
+		_, err = time.Parse("2006-01-02 15:04:05.999999", *c.Value)
+	case "int":
+		_, err = strconv.Atoi(*c.Value)
+	case "real":
+		_, err = strconv.ParseFloat(*c.Value, 32)
+	}
+
+	return err == nil
+}
+
+// after returns the condition selecting the rows that come after c when ordering by
+// expr (then id) with NULLS LAST. cast is the Postgres type of expr, arg adds
+// a query argument and returns its placeholder.
+func (c *Cursor) after(expr, cast string, arg func(any) string) string {
+	op := ">"
+
+	if c.Desc {
+		op = "<"
+	}
+
+	id := arg(c.ID)
+
+	if c.Value == nil {
+		return fmt.Sprintf("(%s IS NULL AND id %s %s)", expr, op, id)
+	}
+
+	value := arg(*c.Value) + "::" + cast
+
+	return fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s %s) OR %s IS NULL)", expr, op, value, expr, value, op, id, expr)
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func text(s string) *string {
+	return &s
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []Cursor{
+		{Sort: "created", Desc: true, Value: text("2026-01-02 15:04:05.123456"), ID: 42},
+		{Sort: "due", Value: nil, ID: 7},
+		{Sort: "title", Value: text("write \"report\", ünïcode"), ID: 1},
+	}
+
+	for _, cursor := range cursors {
+		decoded, err := DecodeCursor(cursor.Encode())
+
+		if err != nil {
+			t.Fatalf("%+v: %v", cursor, err)
+		}
+
+		if !reflect.DeepEqual(*decoded, cursor) {
+			t.Fatalf("expected %+v back, got %+v", cursor, *decoded)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	if cursor, err := DecodeCursor(""); cursor != nil || err != nil {
+		t.Fatalf("an empty token is no cursor, got %+v, %v", cursor, err)
+	}
+
+	tokens := map[string]string{
+		"not base64":  "%%%",
+		"not json":    base64.RawURLEncoding.EncodeToString([]byte("{")),
+		"no id":       base64.RawURLEncoding.EncodeToString([]byte(`{"s":"created"}`)),
+		"negative id": base64.RawURLEncoding.EncodeToString([]byte(`{"s":"created","i":-3}`)),
+		"wrong type":  base64.RawURLEncoding.EncodeToString([]byte(`{"s":"created","i":"3"}`)),
+		"truncated":   Cursor{Sort: "created", ID: 3}.Encode()[:10],
+	}
+
+	for name, token := range tokens {
+		if _, err := DecodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: expected ErrInvalidCursor, got %v", name, err)
+		}
+	}
+}
+
+func TestCursorValid(t *testing.T) {
+	cases := []struct {
+		value *string
+		cast  string
+		valid bool
+	}{
+		{nil, "timestamp", true},
+		{text("2026-01-02 15:04:05.123456"), "timestamp", true},
+		{text("2026-01-02 15:04:05"), "timestamp", true},
+		{text("2026-01-02"), "timestamp", false},
+		{text("'; DROP TABLE tasks; --"), "timestamp", false},
+		{text("3"), "int", true},
+		{text("3.5"), "int", false},
+		{text("0.0607927"), "real", true},
+		{text("1e-20"), "real", true},
+		{text("high"), "real", false},
+		{text("anything at all"), "text", true},
+	}
+
+	for _, c := range cases {
+		cursor := Cursor{Sort: "s", Value: c.value, ID: 1}
+
+		if got := cursor.valid(c.cast); got != c.valid {
+			value := "<nil>"
+
+			if c.value != nil {
+				value = *c.value
+			}
+
+			t.Errorf("%q as %s: expected valid %v, got %v", value, c.cast, c.valid, got)
+		}
+	}
+}
+
+func TestCursorAfter(t *testing.T) {
+	cases := []struct {
+		name   string
+		cursor Cursor
+		want   string
+		args   []any
+	}{
+		{
+			name:   "ascending",
+			cursor: Cursor{Value: text("2026-01-02 00:00:00"), ID: 5},
+			want:   "(due_date > $2::timestamp OR (due_date = $2::timestamp AND id > $1) OR due_date IS NULL)",
+			args:   []any{5, "2026-01-02 00:00:00"},
+		},
+		{
+			name:   "descending",
+			cursor: Cursor{Desc: true, Value: text("2026-01-02 00:00:00"), ID: 5},
+			want:   "(due_date < $2::timestamp OR (due_date = $2::timestamp AND id < $1) OR due_date IS NULL)",
+			args:   []any{5, "2026-01-02 00:00:00"},
+		},
+		{
+			name:   "null key ascending",
+			cursor: Cursor{ID: 9},
+			want:   "(due_date IS NULL AND id > $1)",
+			args:   []any{9},
+		},
+		{
+			name:   "null key descending",
+			cursor: Cursor{Desc: true, ID: 9},
+			want:   "(due_date IS NULL AND id < $1)",
+			args:   []any{9},
+		},
+	}
+
+	for _, c := range cases {
+		var args []any
+
+		arg := func(value any) string {
+			args = append(args, value)
+			return fmt.Sprintf("$%d", len(args))
+		}
+
+		if got := c.cursor.after("due_date", "timestamp", arg); got != c.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", c.name, c.want, got)
+		}
+
+		if !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%s: expected args %v, got %v", c.name, c.args, args)
+		}
+	}
+}
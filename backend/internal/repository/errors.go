@@ -0,0 +1,27 @@
+package repository
+
+import "errors"
+
+// ErrNotFound is returned when a row does not exist or is not owned by the user
+var ErrNotFound = errors.New("not found")
+
+// ErrSubtaskOrder is returned when a new order does not list every subtask exactly once
+var ErrSubtaskOrder = errors.New("order must list every subtask exactly once")
+
+// ErrDuplicateTag is returned when the user already has a tag by that name
+var ErrDuplicateTag = errors.New("a tag with that name already exists")
+
+// ErrUnknownTag is returned when a task is given a tag the user does not have
+var ErrUnknownTag = errors.New("unknown tag")
+
+// ErrUnknownProject is returned when a task is put in a project the user does not have
+var ErrUnknownProject = errors.New("unknown project")
+
+// ErrProjectOrder is returned when a new order does not list every project exactly once
+var ErrProjectOrder = errors.New("order must list every project exactly once")
+
+// ErrParentTrashed is returned when restoring a subtask whose parent is still in the trash
+var ErrParentTrashed = errors.New("restore the parent task first")
+
+// ErrUndoConflict is returned when a task was changed again after the operation being undone or redone
+var ErrUndoConflict = errors.New("the task was changed since, this can no longer be undone")
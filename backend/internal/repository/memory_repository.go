@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+type MemoryRepository struct {
+	DB *sql.DB
+}
+
+func NewMemoryRepository(db *sql.DB) *MemoryRepository {
+	return &MemoryRepository{DB: db}
+}
+
+const memoryColumns = `id, user_id, kind, content, source, created_at, updated_at`
+
+func (r *MemoryRepository) Create(memory *models.MentorMemory) error {
+	query := `
+		INSERT INTO mentor_memories (user_id, kind, content, source)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`
+
+	return r.DB.QueryRow(query, memory.UserID, memory.Kind, memory.Content, memory.Source).
+		Scan(&memory.ID, &memory.CreatedAt, &memory.UpdatedAt)
+}
+
+// List returns the memories of a user, newest first. An empty kind returns every kind.
+func (r *MemoryRepository) List(userID int, kind string) ([]models.MentorMemory, error) {
+	query := `
+		SELECT ` + memoryColumns + ` FROM mentor_memories
+		WHERE user_id = $1 AND ($2 = '' OR kind = $2)
+		ORDER BY updated_at DESC, id DESC
+	`
+
+	rows, err := r.DB.Query(query, userID, kind)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	memories := []models.MentorMemory{}
+
+	for rows.Next() {
+		var memory models.MentorMemory
+
+		if err := rows.Scan(&memory.ID, &memory.UserID, &memory.Kind, &memory.Content, &memory.Source, &memory.CreatedAt, &memory.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		memories = append(memories, memory)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return memories, nil
+}
+
+func (r *MemoryRepository) Update(memory *models.MentorMemory) error {
+	query := `
+		UPDATE mentor_memories SET kind = $1, content = $2, updated_at = NOW()
+		WHERE id = $3 AND user_id = $4
+		RETURNING source, created_at, updated_at
+	`
+
+	err := r.DB.QueryRow(query, memory.Kind, memory.Content, memory.ID, memory.UserID).
+		Scan(&memory.Source, &memory.CreatedAt, &memory.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func (r *MemoryRepository) Delete(id, userID int) error {
+	result, err := r.DB.Exec(`DELETE FROM mentor_memories WHERE id = $1 AND user_id = $2`, id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/lib/pq"
+)
+
+type NudgeRepository struct {
+	DB *sql.DB
+}
+
+func NewNudgeRepository(db *sql.DB) *NudgeRepository {
+	return &NudgeRepository{DB: db}
+}
+
+// GetSettings returns the user's nudge settings, disabled with no times if never set
+func (r *NudgeRepository) GetSettings(userID int) (*models.NudgeSettings, error) {
+	settings := &models.NudgeSettings{UserID: userID, Times: []string{}}
+
+	query := `SELECT enabled, times, updated_at FROM nudge_settings WHERE user_id = $1`
+
+	err := r.DB.QueryRow(query, userID).Scan(&settings.Enabled, pq.Array(&settings.Times), &settings.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+
+	return settings, err
+}
+
+func (r *NudgeRepository) SaveSettings(settings *models.NudgeSettings) error {
+	query := `
+		INSERT INTO nudge_settings (user_id, enabled, times)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET enabled = EXCLUDED.enabled, times = EXCLUDED.times, updated_at = NOW()
+		RETURNING updated_at
+	`
+
+	return r.DB.QueryRow(query, settings.UserID, settings.Enabled, pq.Array(settings.Times)).Scan(&settings.UpdatedAt)
+}
+
+// ListEnabled returns the settings of every user who wants nudges
+func (r *NudgeRepository) ListEnabled() ([]models.NudgeSettings, error) {
+	query := `SELECT user_id, enabled, times, updated_at FROM nudge_settings WHERE enabled AND cardinality(times) > 0`
+
+	rows, err := r.DB.Query(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	all := []models.NudgeSettings{}
+
+	for rows.Next() {
+		var settings models.NudgeSettings
+
+		if err := rows.Scan(&settings.UserID, &settings.Enabled, pq.Array(&settings.Times), &settings.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		all = append(all, settings)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// ClaimRun records that the nudge for user, date (YYYY-MM-DD) and slot is being handled.
+// It returns the run id, or ok false if the run was already claimed, by this server or another.
+func (r *NudgeRepository) ClaimRun(userID int, date, slot string) (int, bool, error) {
+	query := `
+		INSERT INTO nudge_runs (user_id, run_date, slot) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, run_date, slot) DO NOTHING
+		RETURNING id
+	`
+
+	var id int
+
+	err := r.DB.QueryRow(query, userID, date, slot).Scan(&id)
+
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	return id, true, nil
+}
+
+// ReleaseRun drops a claimed run that failed, so the next tick within the window can retry it
+func (r *NudgeRepository) ReleaseRun(runID int) error {
+	_, err := r.DB.Exec(`DELETE FROM nudge_runs WHERE id = $1`, runID)
+
+	return err
+}
+
+// FinishRun stores why a run nudged and the message it sent. chatID 0 means it stayed quiet.
+func (r *NudgeRepository) FinishRun(runID int, reason string, chatID int) error {
+	query := `UPDATE nudge_runs SET reason = NULLIF($2, ''), chat_id = NULLIF($3, 0) WHERE id = $1`
+
+	_, err := r.DB.Exec(query, runID, reason, chatID)
+
+	return err
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+type PlanRepository struct {
+	DB *sql.DB
+}
+
+func NewPlanRepository(db *sql.DB) *PlanRepository {
+	return &PlanRepository{DB: db}
+}
+
+// planBody is the part of a plan stored as JSON
+type planBody struct {
+	Items     []models.PlanItem     `json:"items"`
+	Deferrals []models.PlanDeferral `json:"deferrals"`
+	Notes     string                `json:"notes"`
+}
+
+func (r *PlanRepository) Create(plan *models.DailyPlan) error {
+	body, err := json.Marshal(planBody{Items: plan.Items, Deferrals: plan.Deferrals, Notes: plan.Notes})
+
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO daily_plans (user_id, plan_date, plan) VALUES ($1, $2, $3) RETURNING id, created_at`
+
+	return r.DB.QueryRow(query, plan.UserID, plan.PlanDate, body).Scan(&plan.ID, &plan.CreatedAt)
+}
+
+func (r *PlanRepository) GetByID(id, userID int) (*models.DailyPlan, error) {
+	query := `SELECT id, user_id, plan_date, plan, accepted_at, created_at FROM daily_plans WHERE id = $1 AND user_id = $2`
+
+	return scanPlan(r.DB.QueryRow(query, id, userID))
+}
+
+// GetLatest returns the most recent plan made for date (YYYY-MM-DD)
+func (r *PlanRepository) GetLatest(userID int, date string) (*models.DailyPlan, error) {
+	query := `
+		SELECT id, user_id, plan_date, plan, accepted_at, created_at FROM daily_plans
+		WHERE user_id = $1 AND plan_date = $2
+		ORDER BY id DESC
+		LIMIT 1
+	`
+
+	return scanPlan(r.DB.QueryRow(query, userID, date))
+}
+
+func scanPlan(row *sql.Row) (*models.DailyPlan, error) {
+	var plan models.DailyPlan
+	var planDate sql.NullTime
+	var body []byte
+	var accepted sql.NullTime
+
+	err := row.Scan(&plan.ID, &plan.UserID, &planDate, &body, &accepted, &plan.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var stored planBody
+
+	if err := json.Unmarshal(body, &stored); err != nil {
+		return nil, err
+	}
+
+	plan.PlanDate = planDate.Time.Format("2006-01-02")
+	plan.Items = stored.Items
+	plan.Deferrals = stored.Deferrals
+	plan.Notes = stored.Notes
+
+	if accepted.Valid {
+		plan.AcceptedAt = &accepted.Time
+	}
+
+	return &plan, nil
+}
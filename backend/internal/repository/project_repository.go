@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+type ProjectRepository struct {
+	DB     *sql.DB
+	Source string // who the history of the tasks a change touches credits, models.SourceUser when empty
+}
+
+func NewProjectRepository(db *sql.DB) *ProjectRepository {
+	return &ProjectRepository{DB: db}
+}
+
+// WithSource returns a copy of the repository recording its changes to tasks as made by source
+func (r *ProjectRepository) WithSource(source string) *ProjectRepository {
+	copy := *r
+	copy.Source = source
+
+	return &copy
+}
+
+func (r *ProjectRepository) source() string {
+	if r.Source == "" {
+		return models.SourceUser
+	}
+
+	return r.Source
+}
+
+// projects with the completion stats of their tasks, in the order scanProject expects
+const projectQuery = `
+	SELECT p.id, p.user_id, p.name, p.description, p.color, p.archived, p.position, p.created_at, p.updated_at,
+		COUNT(t.id),
+		COUNT(t.id) FILTER (WHERE t.status = 'complete'),
+		COUNT(t.id) FILTER (WHERE t.status <> 'complete' AND t.due_date < CURRENT_DATE)
+	FROM projects p
+	LEFT JOIN tasks t ON t.project_id = p.id AND t.deleted_at IS NULL
+`
+
+func scanProject(row rowScanner) (models.Project, error) {
+	var project models.Project
+	var color sql.NullString
+	var stats models.ProjectStats
+
+	err := row.Scan(&project.ID, &project.UserID, &project.Name, &project.Description, &color, &project.Archived, &project.Position,
+		&project.CreatedAt, &project.UpdatedAt, &stats.Total, &stats.Completed, &stats.Overdue)
+
+	if err != nil {
+		return project, err
+	}
+
+	project.Color = color.String
+
+	if stats.Total > 0 {
+		stats.Percent = stats.Completed * 100 / stats.Total
+	}
+
+	project.Stats = &stats
+
+	return project, nil
+}
+
+// List returns the user's projects in their manual order, archived ones only when asked
+func (r *ProjectRepository) List(userID int, includeArchived bool) ([]models.Project, error) {
+	query := projectQuery + `
+		WHERE p.user_id = $1 AND ($2 OR NOT p.archived)
+		GROUP BY p.id
+		ORDER BY p.position, p.id
+	`
+
+	rows, err := r.DB.Query(query, userID, includeArchived)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	projects := []models.Project{}
+
+	for rows.Next() {
+		project, err := scanProject(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		projects = append(projects, project)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+func (r *ProjectRepository) GetByID(id, userID int) (*models.Project, error) {
+	query := projectQuery + `
+		WHERE p.id = $1 AND p.user_id = $2
+		GROUP BY p.id
+	`
+
+	project, err := scanProject(r.DB.QueryRow(query, id, userID))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &project, nil
+}
+
+// Create adds a project after the user's existing ones
+func (r *ProjectRepository) Create(project *models.Project) error {
+	query := `
+		INSERT INTO projects (user_id, name, description, color, archived, position)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, (SELECT COALESCE(MAX(position) + 1, 0) FROM projects WHERE user_id = $1))
+		RETURNING id, position, created_at, updated_at
+	`
+
+	return r.DB.QueryRow(query, project.UserID, project.Name, project.Description, project.Color, project.Archived).
+		Scan(&project.ID, &project.Position, &project.CreatedAt, &project.UpdatedAt)
+}
+
+func (r *ProjectRepository) Update(project *models.Project) error {
+	query := `
+		UPDATE projects SET name = $1, description = $2, color = NULLIF($3, ''), archived = $4, updated_at = NOW()
+		WHERE id = $5 AND user_id = $6
+		RETURNING position, created_at, updated_at
+	`
+
+	err := r.DB.QueryRow(query, project.Name, project.Description, project.Color, project.Archived, project.ID, project.UserID).
+		Scan(&project.Position, &project.CreatedAt, &project.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+// Delete removes a project. Its tasks go to the trash with it when deleteTasks is set,
+// otherwise they move to the inbox.
+func (r *ProjectRepository) Delete(id, userID int, deleteTasks bool) error {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if deleteTasks {
+		//to the trash, from where they are restored to the inbox
+		query := `UPDATE tasks SET deleted_at = NOW(), updated_at = NOW() WHERE project_id = $1 AND user_id = $2 AND deleted_at IS NULL RETURNING id`
+
+		ids, err := queryIDs(tx, query, id, userID)
+
+		if err != nil {
+			return err
+		}
+
+		if err := recordTrash(tx, userID, ids, "deleted", r.source()); err != nil {
+			return err
+		}
+	}
+
+	//the rest move to the inbox; ON DELETE SET NULL would too, but leave no trace in their history
+	ids, err := queryIDs(tx, `UPDATE tasks SET project_id = NULL, updated_at = NOW() WHERE project_id = $1 AND user_id = $2 RETURNING id`, id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	var projectChanges changes
+	projectChanges.add("project_id", idValue(&id), nil)
+
+	for _, taskID := range ids {
+		if err := recordEvents(tx, &models.Task{ID: int(taskID), UserID: userID}, r.source(), projectChanges...); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM projects WHERE id = $1 AND user_id = $2`, id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return tx.Commit()
+}
+
+// Reorder sets the order of the user's projects from ids, which must list each of them exactly once
+func (r *ProjectRepository) Reorder(userID int, ids []int) error {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id FROM projects WHERE user_id = $1 FOR UPDATE`, userID)
+
+	if err != nil {
+		return err
+	}
+
+	current := map[int]bool{}
+
+	for rows.Next() {
+		var id int
+
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+
+		current[id] = true
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(ids) != len(current) {
+		return ErrProjectOrder
+	}
+
+	for position, id := range ids {
+		if !current[id] {
+			return ErrProjectOrder
+		}
+
+		//a duplicate id would leave another project out
+		delete(current, id)
+
+		if _, err := tx.Exec(`UPDATE projects SET position = $1, updated_at = NOW() WHERE id = $2`, position, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+type ReviewRepository struct {
+	DB *sql.DB
+}
+
+func NewReviewRepository(db *sql.DB) *ReviewRepository {
+	return &ReviewRepository{DB: db}
+}
+
+// reviewBody is the part of a review written by the mentor, stored as JSON
+type reviewBody struct {
+	Wins         []string `json:"wins"`
+	Patterns     []string `json:"patterns"`
+	SystemChange string   `json:"system_change"`
+}
+
+// Save stores the review of a week, replacing an earlier one for the same week
+func (r *ReviewRepository) Save(review *models.WeeklyReview) error {
+	stats, err := json.Marshal(review.Stats)
+
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(reviewBody{Wins: review.Wins, Patterns: review.Patterns, SystemChange: review.SystemChange})
+
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO weekly_reviews (user_id, week_start, stats, review)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, week_start)
+		DO UPDATE SET stats = EXCLUDED.stats, review = EXCLUDED.review, created_at = NOW()
+		RETURNING id, created_at
+	`
+
+	return r.DB.QueryRow(query, review.UserID, review.WeekStart, stats, body).Scan(&review.ID, &review.CreatedAt)
+}
+
+// List returns the user's reviews, most recent week first
+func (r *ReviewRepository) List(userID, limit int) ([]models.WeeklyReview, error) {
+	query := `
+		SELECT id, user_id, week_start, stats, review, created_at FROM weekly_reviews
+		WHERE user_id = $1
+		ORDER BY week_start DESC
+		LIMIT $2
+	`
+
+	rows, err := r.DB.Query(query, userID, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	reviews := []models.WeeklyReview{}
+
+	for rows.Next() {
+		review, err := scanReview(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		reviews = append(reviews, *review)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+func (r *ReviewRepository) GetByID(id, userID int) (*models.WeeklyReview, error) {
+	query := `SELECT id, user_id, week_start, stats, review, created_at FROM weekly_reviews WHERE id = $1 AND user_id = $2`
+
+	review, err := scanReview(r.DB.QueryRow(query, id, userID))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	return review, err
+}
+
+func scanReview(row rowScanner) (*models.WeeklyReview, error) {
+	var review models.WeeklyReview
+	var weekStart sql.NullTime
+	var stats, body []byte
+
+	if err := row.Scan(&review.ID, &review.UserID, &weekStart, &stats, &body, &review.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	var stored reviewBody
+
+	if err := json.Unmarshal(body, &stored); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(stats, &review.Stats); err != nil {
+		return nil, err
+	}
+
+	review.WeekStart = weekStart.Time.Format("2006-01-02")
+	review.Wins = stored.Wins
+	review.Patterns = stored.Patterns
+	review.SystemChange = stored.SystemChange
+
+	return &review, nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/lib/pq"
+)
+
+type TagRepository struct {
+	DB *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) *TagRepository {
+	return &TagRepository{DB: db}
+}
+
+// List returns the user's tags by name, with how many tasks carry each and how many of those are complete
+func (r *TagRepository) List(userID int) ([]models.Tag, error) {
+	query := `
+		SELECT g.id, g.name, g.color, g.created_at,
+			COUNT(t.id), COUNT(t.id) FILTER (WHERE t.status = 'complete')
+		FROM tags g
+		LEFT JOIN task_tags tt ON tt.tag_id = g.id
+		LEFT JOIN tasks t ON t.id = tt.task_id AND t.deleted_at IS NULL
+		WHERE g.user_id = $1
+		GROUP BY g.id
+		ORDER BY LOWER(g.name)
+	`
+
+	rows, err := r.DB.Query(query, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tags := []models.Tag{}
+
+	for rows.Next() {
+		tag := models.Tag{UserID: userID}
+
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.TaskCount, &tag.CompletedCount); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (r *TagRepository) Create(tag *models.Tag) error {
+	query := `INSERT INTO tags (user_id, name, color) VALUES ($1, $2, $3) RETURNING id, created_at`
+
+	err := r.DB.QueryRow(query, tag.UserID, tag.Name, tag.Color).Scan(&tag.ID, &tag.CreatedAt)
+
+	return tagError(err)
+}
+
+func (r *TagRepository) Update(tag *models.Tag) error {
+	query := `UPDATE tags SET name = $1, color = $2 WHERE id = $3 AND user_id = $4 RETURNING created_at`
+
+	err := r.DB.QueryRow(query, tag.Name, tag.Color, tag.ID, tag.UserID).Scan(&tag.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+
+	return tagError(err)
+}
+
+// Delete removes a tag, and with it from every task that had it
+func (r *TagRepository) Delete(id, userID int) error {
+	result, err := r.DB.Exec(`DELETE FROM tags WHERE id = $1 AND user_id = $2`, id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// tagError turns the unique name violation into ErrDuplicateTag
+func tagError(err error) error {
+	var pqErr *pq.Error
+
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateTag
+	}
+
+	return err
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/lib/pq"
+)
+
+// fieldChange is one changed field of a task, about to become an event
+type fieldChange struct {
+	field    string
+	old, new *string
+}
+
+// changes collects the fields of a task an update actually changes
+type changes []fieldChange
+
+func (c *changes) add(field string, old, new *string) {
+	if old == nil && new == nil || old != nil && new != nil && *old == *new {
+		return
+	}
+
+	*c = append(*c, fieldChange{field: field, old: old, new: new})
+}
+
+// the text stored for each kind of value, nil for an empty one
+func textValue(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+func dateValue(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+
+	return textValue(t.Format("2006-01-02"))
+}
+
+func boolValue(b bool) *string {
+	return textValue(strconv.FormatBool(b))
+}
+
+func idValue(id *int) *string {
+	if id == nil {
+		return nil
+	}
+
+	return textValue(strconv.Itoa(*id))
+}
+
+func tagsValue(tags []models.Tag) *string {
+	names := make([]string, len(tags))
+
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+
+	return textValue(strings.Join(names, ", "))
+}
+
+// recordEvents writes the history of a change to task within the transaction making it
+func recordEvents(tx *sql.Tx, task *models.Task, source string, changes ...fieldChange) error {
+	query := `INSERT INTO task_events (task_id, user_id, field, old_value, new_value, source) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	for _, change := range changes {
+		if _, err := tx.Exec(query, task.ID, task.UserID, change.field, change.old, change.new, source); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordTrash writes a deleted or restored event for each of ids
+func recordTrash(tx *sql.Tx, userID int, ids []int64, field, source string) error {
+	query := `INSERT INTO task_events (task_id, user_id, field, source) SELECT unnest($1::int[]), $2, $3, $4`
+
+	_, err := tx.Exec(query, pq.Array(ids), userID, field, source)
+
+	return err
+}
+
+// History returns the changes made to a task, oldest first. Tasks in the trash keep theirs.
+func (r *TaskRepository) History(taskID, userID int) ([]models.TaskEvent, error) {
+	var exists bool
+
+	if err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND user_id = $2)`, taskID, userID).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	query := `
+		SELECT id, task_id, field, old_value, new_value, source, created_at FROM task_events
+		WHERE task_id = $1 AND user_id = $2
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.DB.Query(query, taskID, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := []models.TaskEvent{}
+
+	for rows.Next() {
+		var event models.TaskEvent
+		var old, new sql.NullString
+
+		if err := rows.Scan(&event.ID, &event.TaskID, &event.Field, &old, &new, &event.Source, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if old.Valid {
+			event.OldValue = &old.String
+		}
+
+		if new.Valid {
+			event.NewValue = &new.String
+		}
+
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// RescheduleCounts returns, for each open task of the user, how many times its due date
+// was pushed back or cleared. Tasks never postponed are left out.
+func (r *TaskRepository) RescheduleCounts(userID int) (map[int]int, error) {
+	query := `
+		SELECT e.task_id, COUNT(*) FROM task_events e
+		JOIN tasks t ON t.id = e.task_id
+		WHERE e.user_id = $1 AND e.field = 'due_date' AND e.old_value IS NOT NULL
+		  AND (e.new_value IS NULL OR e.new_value > e.old_value)
+		  AND t.status <> 'complete' AND t.deleted_at IS NULL
+		GROUP BY e.task_id
+	`
+
+	rows, err := r.DB.Query(query, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := map[int]int{}
+
+	for rows.Next() {
+		var taskID, count int
+
+		if err := rows.Scan(&taskID, &count); err != nil {
+			return nil, err
+		}
+
+		counts[taskID] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// whereOf returns the WHERE clause of a task list query with its whitespace collapsed
+func whereOf(query string) string {
+	_, where, _ := strings.Cut(query, " FROM tasks WHERE ")
+	where, _, _ = strings.Cut(where, " ORDER BY ")
+
+	return strings.Join(strings.Fields(where), " ")
+}
+
+func TestTaskListQueryWhere(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	base := "user_id = $1 AND deleted_at IS NULL"
+
+	cases := []struct {
+		name   string
+		filter TaskFilter
+		where  string
+		args   []any
+	}{
+		{"everything", TaskFilter{}, base, []any{7}},
+		{
+			"statuses and priorities",
+			TaskFilter{Statuses: []string{"todo", "in_progress"}, Priorities: []string{"high"}},
+			base + " AND status = ANY($2) AND priority = ANY($3)",
+			[]any{7, pq.Array([]string{"todo", "in_progress"}), pq.Array([]string{"high"})},
+		},
+		{"project", TaskFilter{ProjectID: 4}, base + " AND project_id = $2", []any{7, 4}},
+		{"inbox", TaskFilter{Inbox: true}, base + " AND project_id IS NULL", []any{7}},
+		{"project wins over inbox", TaskFilter{ProjectID: 4, Inbox: true}, base + " AND project_id = $2", []any{7, 4}},
+		{"roots", TaskFilter{RootsOnly: true}, base + " AND parent_id IS NULL", []any{7}},
+		{
+			"due range",
+			TaskFilter{DueFrom: &from, DueBefore: &before},
+			base + " AND due_date >= $2 AND due_date < $3",
+			[]any{7, from, before},
+		},
+		{
+			"completed range",
+			TaskFilter{CompletedFrom: &from, CompletedBefore: &before},
+			base + " AND completed_at >= $2 AND completed_at < $3",
+			[]any{7, from, before},
+		},
+		{"overdue", TaskFilter{Overdue: true}, base + " AND status <> 'complete' AND due_date < CURRENT_DATE", []any{7}},
+		{
+			"any tag, names cleaned up",
+			TaskFilter{Tags: []string{" Health", "health", "", "WORK"}},
+			base + " AND ( SELECT COUNT(DISTINCT LOWER(g.name)) FROM task_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.task_id = tasks.id AND LOWER(g.name) = ANY($2) ) > 0",
+			[]any{7, pq.Array([]string{"health", "work"})},
+		},
+		{
+			"every tag",
+			TaskFilter{Tags: []string{"health", "work"}, AllTags: true},
+			base + " AND ( SELECT COUNT(DISTINCT LOWER(g.name)) FROM task_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.task_id = tasks.id AND LOWER(g.name) = ANY($2) ) = cardinality($2::text[])",
+			[]any{7, pq.Array([]string{"health", "work"})},
+		},
+		{
+			"search",
+			TaskFilter{Query: "  gym -cardio "},
+			base + " AND search_vector @@ websearch_to_tsquery('english', $2)",
+			[]any{7, "gym -cardio"},
+		},
+		{
+			"combined",
+			TaskFilter{Tags: []string{"work"}, ProjectID: 2, Statuses: []string{"todo"}, DueBefore: &before},
+			base + " AND ( SELECT COUNT(DISTINCT LOWER(g.name)) FROM task_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.task_id = tasks.id AND LOWER(g.name) = ANY($2) ) > 0" +
+				" AND project_id = $3 AND status = ANY($4) AND due_date < $5",
+			[]any{7, pq.Array([]string{"work"}), 2, pq.Array([]string{"todo"}), before},
+		},
+	}
+
+	for _, c := range cases {
+		list, err := taskListQuery(7, c.filter, nil, 0)
+
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		if got := whereOf(list.query); got != c.where {
+			t.Errorf("%s: expected WHERE\n%s\ngot\n%s", c.name, c.where, got)
+		}
+
+		if !reflect.DeepEqual(list.args, c.args) {
+			t.Errorf("%s: expected args %v, got %v", c.name, c.args, list.args)
+		}
+	}
+}
+
+func TestTaskListQueryOrder(t *testing.T) {
+	cases := []struct {
+		name      string
+		filter    TaskFilter
+		sort      string
+		desc      bool
+		orderedBy string
+	}{
+		{"newest first by default", TaskFilter{}, "created", true, "ORDER BY created_at DESC NULLS LAST, id DESC"},
+		{"ascending due date", TaskFilter{Sort: "due"}, "due", false, "ORDER BY due_date ASC NULLS LAST, id ASC"},
+		{"best match first for a search", TaskFilter{Query: "gym"}, "relevance", true, "ORDER BY ts_rank(search_vector, websearch_to_tsquery('english', $2)) DESC NULLS LAST, id DESC"},
+		{"relevance needs a search", TaskFilter{Sort: "relevance"}, "created", true, "ORDER BY created_at DESC NULLS LAST, id DESC"},
+		{"unknown sort", TaskFilter{Sort: "size"}, "created", true, "ORDER BY created_at DESC NULLS LAST, id DESC"},
+	}
+
+	for _, c := range cases {
+		list, err := taskListQuery(7, c.filter, nil, 0)
+
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		if list.sort != c.sort || list.desc != c.desc {
+			t.Errorf("%s: expected sort %s desc %v, got %s desc %v", c.name, c.sort, c.desc, list.sort, list.desc)
+		}
+
+		if !strings.HasSuffix(list.query, c.orderedBy) {
+			t.Errorf("%s: expected the query to end with\n%s\ngot\n%s", c.name, c.orderedBy, list.query)
+		}
+	}
+}
+
+func TestTaskListQueryPage(t *testing.T) {
+	cursor := &Cursor{Sort: "due", Value: text("2026-01-02 00:00:00"), ID: 5}
+
+	list, err := taskListQuery(7, TaskFilter{Sort: "due", Overdue: true}, cursor, 20)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "user_id = $1 AND deleted_at IS NULL AND status <> 'complete' AND due_date < CURRENT_DATE" +
+		" AND (due_date > $3::timestamp OR (due_date = $3::timestamp AND id > $2) OR due_date IS NULL)"
+
+	if got := whereOf(list.query); got != want {
+		t.Errorf("expected WHERE\n%s\ngot\n%s", want, got)
+	}
+
+	//one row more than the page tells whether there is another
+	if !strings.HasSuffix(list.query, "LIMIT $4") || !reflect.DeepEqual(list.args, []any{7, 5, "2026-01-02 00:00:00", 21}) {
+		t.Errorf("expected LIMIT $4 with 21, got %q with %v", list.query[len(list.query)-10:], list.args)
+	}
+}
+
+func TestTaskListQueryInvalidCursor(t *testing.T) {
+	cursors := map[string]*Cursor{
+		"another sort":      {Sort: "title", Desc: true, Value: text("a"), ID: 1},
+		"another direction": {Sort: "created", Value: text("2026-01-02 00:00:00"), ID: 1},
+		"edited key":        {Sort: "created", Desc: true, Value: text("yesterday"), ID: 1},
+	}
+
+	for name, cursor := range cursors {
+		if _, err := taskListQuery(7, TaskFilter{}, cursor, 20); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: expected ErrInvalidCursor, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package repository
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/lib/pq"
+)
+
+// taskState is a task row as an operation found or left it, with its tags
+type taskState struct {
+	models.Task
+	TagIDs []int64 `json:"tag_ids"`
+}
+
+// operationStep is what one transaction of an operation did to the tasks it touched.
+// A task missing from Before was created by it.
+type operationStep struct {
+	Before []taskState `json:"before"`
+	After  []taskState `json:"after"`
+}
+
+// WithOperation returns a copy of the repository recording the changes made through
+// it as one operation of kind, which Undo can revert. The token is in Operation.
+func (r *TaskRepository) WithOperation(kind string) *TaskRepository {
+	copy := *r
+	copy.Operation = rand.Text()
+	copy.OperationKind = kind
+
+	return &copy
+}
+
+// beginStep saves the tasks a change is about to touch, nil when the change is not part of an operation
+func (r *TaskRepository) beginStep(tx *sql.Tx, userID int, ids []int64) (*operationStep, error) {
+	if r.Operation == "" {
+		return nil, nil
+	}
+
+	before, err := snapshotTasks(tx, userID, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &operationStep{Before: before}, nil
+}
+
+// endStep saves the touched tasks again, ids including any the change created, and adds the step to the operation
+func (r *TaskRepository) endStep(tx *sql.Tx, userID int, step *operationStep, ids []int64) error {
+	if step == nil {
+		return nil
+	}
+
+	after, err := snapshotTasks(tx, userID, ids)
+
+	if err != nil {
+		return err
+	}
+
+	step.After = after
+
+	body, err := json.Marshal([]operationStep{*step})
+
+	if err != nil {
+		return err
+	}
+
+	//a new operation starts a new branch, what was undone before can no longer be redone
+	if _, err := tx.Exec(`DELETE FROM task_operations WHERE user_id = $1 AND undone_at IS NOT NULL`, userID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO task_operations (user_id, token, kind, steps) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (token) DO UPDATE SET steps = task_operations.steps || EXCLUDED.steps
+	`
+
+	_, err = tx.Exec(query, userID, r.Operation, r.OperationKind, body)
+
+	return err
+}
+
+// snapshotTasks reads the rows of ids, in the trash or not, parents before the subtasks created after them
+func snapshotTasks(tx *sql.Tx, userID int, ids []int64) ([]taskState, error) {
+	query := `
+		SELECT ` + taskColumns + `, ARRAY(SELECT tag_id FROM task_tags WHERE task_id = tasks.id ORDER BY tag_id)
+		FROM tasks WHERE user_id = $1 AND id = ANY($2)
+		ORDER BY id
+	`
+
+	rows, err := tx.Query(query, userID, pq.Array(ids))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	states := []taskState{}
+
+	for rows.Next() {
+		var state taskState
+
+		if state.Task, err = scanTask(extraScanner{rows, []any{pq.Array(&state.TagIDs)}}); err != nil {
+			return nil, err
+		}
+
+		states = append(states, state)
+	}
+
+	return states, rows.Err()
+}
+
+// ancestry returns the ids of a task and of every task above it
+func ancestry(tx *sql.Tx, id, userID int) ([]int64, error) {
+	query := `
+		WITH RECURSIVE up AS (
+			SELECT id, parent_id FROM tasks WHERE id = $1 AND user_id = $2
+			UNION ALL
+			SELECT t.id, t.parent_id FROM tasks t JOIN up ON t.id = up.parent_id
+		)
+		SELECT id FROM up
+	`
+
+	return queryIDs(tx, query, id, userID)
+}
+
+// Undo reverts the user's most recent operation made since since. Given a token, it
+// reverts that operation and every later one instead, newest first. Either all of
+// them are undone or, if a task was changed again in between, none.
+func (r *TaskRepository) Undo(userID int, token string, since time.Time) ([]models.TaskOperation, error) {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, token, kind, steps, created_at FROM task_operations
+		WHERE user_id = $1 AND undone_at IS NULL AND created_at >= $2
+		ORDER BY id DESC
+		LIMIT 1
+		FOR UPDATE
+	`
+	args := []any{userID, since}
+
+	if token != "" {
+		var first int
+
+		err := tx.QueryRow(`SELECT id FROM task_operations WHERE user_id = $1 AND token = $2 AND undone_at IS NULL AND created_at >= $3`, userID, token, since).Scan(&first)
+
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		query = `
+			SELECT id, token, kind, steps, created_at FROM task_operations
+			WHERE user_id = $1 AND undone_at IS NULL AND id >= $2
+			ORDER BY id DESC
+			FOR UPDATE
+		`
+		args = []any{userID, first}
+	}
+
+	ids, operations, steps, err := loadOperations(tx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(operations) == 0 {
+		return nil, ErrNotFound
+	}
+
+	for i := range operations {
+		for j := len(steps[i]) - 1; j >= 0; j-- {
+			if err := applyStates(tx, userID, steps[i][j].After, steps[i][j].Before, r.source()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var undoneAt time.Time
+
+	if err := tx.QueryRow(`UPDATE task_operations SET undone_at = NOW() WHERE id = ANY($1) RETURNING undone_at`, pq.Array(ids)).Scan(&undoneAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for i := range operations {
+		operations[i].UndoneAt = &undoneAt
+	}
+
+	return operations, nil
+}
+
+// Redo makes again the operation undone last, if it was undone since since. Undoing
+// several at once, they are redone oldest first.
+func (r *TaskRepository) Redo(userID int, since time.Time) (*models.TaskOperation, error) {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, token, kind, steps, created_at FROM task_operations
+		WHERE user_id = $1 AND undone_at >= $2
+		ORDER BY undone_at DESC, id
+		LIMIT 1
+		FOR UPDATE
+	`
+
+	ids, operations, steps, err := loadOperations(tx, query, userID, since)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(operations) == 0 {
+		return nil, ErrNotFound
+	}
+
+	for _, step := range steps[0] {
+		if err := applyStates(tx, userID, step.Before, step.After, r.source()); err != nil {
+			return nil, err
+		}
+	}
+
+	//redone, it can be undone again for as long as a new one
+	operation := operations[0]
+
+	if err := tx.QueryRow(`UPDATE task_operations SET undone_at = NULL, created_at = NOW() WHERE id = $1 RETURNING created_at`, ids[0]).Scan(&operation.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &operation, nil
+}
+
+// PurgeOperations forgets the operations of every user that can no longer be undone or redone
+func (r *TaskRepository) PurgeOperations(cutoff time.Time) (int64, error) {
+	result, err := r.DB.Exec(`DELETE FROM task_operations WHERE created_at < $1 AND (undone_at IS NULL OR undone_at < $1)`, cutoff)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// loadOperations reads the operations a query selects, with their ids and steps
+func loadOperations(tx *sql.Tx, query string, args ...any) ([]int64, []models.TaskOperation, [][]operationStep, error) {
+	rows, err := tx.Query(query, args...)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	defer rows.Close()
+
+	var ids []int64
+	var operations []models.TaskOperation
+	var steps [][]operationStep
+
+	for rows.Next() {
+		var id int64
+		var operation models.TaskOperation
+		var body []byte
+
+		if err := rows.Scan(&id, &operation.Token, &operation.Kind, &body, &operation.CreatedAt); err != nil {
+			return nil, nil, nil, err
+		}
+
+		var stored []operationStep
+
+		if err := json.Unmarshal(body, &stored); err != nil {
+			return nil, nil, nil, err
+		}
+
+		touched := map[int]bool{}
+
+		for _, step := range stored {
+			for _, state := range append(step.Before, step.After...) {
+				if !touched[state.ID] {
+					touched[state.ID] = true
+					operation.TaskIDs = append(operation.TaskIDs, state.ID)
+				}
+			}
+		}
+
+		sort.Ints(operation.TaskIDs)
+
+		ids = append(ids, id)
+		operations = append(operations, operation)
+		steps = append(steps, stored)
+	}
+
+	return ids, operations, steps, rows.Err()
+}
+
+// stateColumns are the columns applyStates writes back, in the order of stateArgs
+const stateColumns = `parent_id, title, description, status, priority, due_date, completed_at, recurrence, series_id, position, auto_complete, project_id, deleted_at, created_at, updated_at`
+
+func stateArgs(state taskState) []any {
+	return []any{
+		state.ParentID,
+		state.Title,
+		state.Description,
+		state.Status,
+		state.Priority,
+		state.DueDate,
+		state.CompletedAt,
+		textValue(state.Recurrence),
+		state.SeriesID,
+		state.Position,
+		state.AutoComplete,
+		state.ProjectID,
+		state.DeletedAt,
+		state.CreatedAt,
+		state.UpdatedAt,
+	}
+}
+
+// applyStates takes the tasks of a step from one side of it to the other: tasks in from
+// must still be exactly as they were left, tasks only in from are deleted, tasks only in
+// to are created again with their old ids and the others are overwritten. Completion
+// dates, and so streaks, come back with the rest of the row.
+func applyStates(tx *sql.Tx, userID int, from, to []taskState, source string) error {
+	current := map[int]*taskState{}
+
+	for i, state := range from {
+		var updatedAt time.Time
+
+		err := tx.QueryRow(`SELECT updated_at FROM tasks WHERE id = $1 AND user_id = $2 FOR UPDATE`, state.ID, userID).Scan(&updatedAt)
+
+		if err == sql.ErrNoRows || err == nil && !updatedAt.Equal(state.UpdatedAt) {
+			return ErrUndoConflict
+		}
+
+		if err != nil {
+			return err
+		}
+
+		current[state.ID] = &from[i]
+	}
+
+	target := map[int]bool{}
+
+	for _, state := range to {
+		target[state.ID] = true
+	}
+
+	removed := []int64{}
+
+	for _, state := range from {
+		if !target[state.ID] {
+			removed = append(removed, int64(state.ID))
+		}
+	}
+
+	if len(removed) > 0 {
+		var adopted bool
+
+		//subtasks added since would go with their parent
+		query := `SELECT EXISTS (SELECT 1 FROM tasks WHERE parent_id = ANY($1) AND NOT id = ANY($1))`
+
+		if err := tx.QueryRow(query, pq.Array(removed)).Scan(&adopted); err != nil {
+			return err
+		}
+
+		if adopted {
+			return ErrUndoConflict
+		}
+
+		if _, err := tx.Exec(`DELETE FROM tasks WHERE id = ANY($1)`, pq.Array(removed)); err != nil {
+			return err
+		}
+	}
+
+	for _, state := range to {
+		state.UserID = userID
+		args := append([]any{state.ID, userID}, stateArgs(state)...)
+		old := current[state.ID]
+
+		var query string
+
+		if old == nil {
+			query = `INSERT INTO tasks (id, user_id, ` + stateColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`
+		} else {
+			query = `UPDATE tasks SET (` + stateColumns + `) = ($3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) WHERE id = $1 AND user_id = $2`
+		}
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			return undoError(err)
+		}
+
+		if _, err := tx.Exec(`DELETE FROM task_tags WHERE task_id = $1`, state.ID); err != nil {
+			return err
+		}
+
+		//tags deleted since stay off
+		if _, err := tx.Exec(`INSERT INTO task_tags (task_id, tag_id) SELECT $1, id FROM tags WHERE user_id = $2 AND id = ANY($3)`, state.ID, userID, pq.Array(state.TagIDs)); err != nil {
+			return err
+		}
+
+		if err := recordEvents(tx, &state.Task, source, stateChanges(old, &state)...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stateChanges lists what going from old to state changes for the task history, old is nil for a task created again
+func stateChanges(old, state *taskState) changes {
+	if old == nil {
+		return changes{{field: "created", new: textValue(state.Title)}}
+	}
+
+	var stateChanges changes
+	stateChanges.add("title", textValue(old.Title), textValue(state.Title))
+	stateChanges.add("description", textValue(old.Description), textValue(state.Description))
+	stateChanges.add("status", textValue(old.Status), textValue(state.Status))
+	stateChanges.add("priority", textValue(old.Priority), textValue(state.Priority))
+	stateChanges.add("due_date", dateValue(old.DueDate), dateValue(state.DueDate))
+	stateChanges.add("recurrence", textValue(old.Recurrence), textValue(state.Recurrence))
+	stateChanges.add("auto_complete", boolValue(old.AutoComplete), boolValue(state.AutoComplete))
+	stateChanges.add("project_id", idValue(old.ProjectID), idValue(state.ProjectID))
+
+	if old.DeletedAt == nil && state.DeletedAt != nil {
+		stateChanges = append(stateChanges, fieldChange{field: "deleted"})
+	}
+
+	if old.DeletedAt != nil && state.DeletedAt == nil {
+		stateChanges = append(stateChanges, fieldChange{field: "restored"})
+	}
+
+	return stateChanges
+}
+
+// undoError turns a task or project deleted since into ErrUndoConflict
+func undoError(err error) error {
+	var pqErr *pq.Error
+
+	if errors.As(err, &pqErr) && (pqErr.Code == "23503" || pqErr.Code == "23505") {
+		return ErrUndoConflict
+	}
+
+	return err
+}
@@ -2,14 +2,86 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/recurrence"
+	"github.com/lib/pq"
 )
 
 type TaskRepository struct {
-	DB *sql.DB
+	DB            *sql.DB
+	Source        string // who the changes made through this repository are recorded as made by, models.SourceUser if empty
+	Operation     string // token of the undoable operation they belong to, see WithOperation
+	OperationKind string
+}
+
+// columns read by every task query, in the order scanTask expects them
+const taskColumns = `id, parent_id, title, description, status, priority, due_date, completed_at, recurrence, series_id, position, auto_complete, project_id, deleted_at, created_at, updated_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// extraScanner scans the columns a query selects after the usual ones into extra
+type extraScanner struct {
+	rowScanner
+	extra []any
+}
+
+func (s extraScanner) Scan(dest ...any) error {
+	return s.rowScanner.Scan(append(dest, s.extra...)...)
+}
+
+func scanTask(row rowScanner) (models.Task, error) {
+	var t models.Task
+	var parent sql.NullInt64
+	var due sql.NullTime
+	var completed sql.NullTime
+	var recurrence sql.NullString
+	var series sql.NullInt64
+	var project sql.NullInt64
+	var deleted sql.NullTime
+
+	if err := row.Scan(&t.ID, &parent, &t.Title, &t.Description, &t.Status, &t.Priority, &due, &completed, &recurrence, &series, &t.Position, &t.AutoComplete, &project, &deleted, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return t, err
+	}
+
+	if parent.Valid {
+		parentID := int(parent.Int64)
+		t.ParentID = &parentID
+	}
+
+	if due.Valid {
+		t.DueDate = &due.Time
+	}
+
+	if completed.Valid {
+		t.CompletedAt = &completed.Time
+	}
+
+	t.Recurrence = recurrence.String
+
+	if series.Valid {
+		seriesID := int(series.Int64)
+		t.SeriesID = &seriesID
+	}
+
+	if project.Valid {
+		projectID := int(project.Int64)
+		t.ProjectID = &projectID
+	}
+
+	if deleted.Valid {
+		t.DeletedAt = &deleted.Time
+	}
+
+	return t, nil
 }
 
 // A function to instantiate TaskRepository struct
@@ -17,61 +89,1413 @@ func NewTaskRepository(db *sql.DB) *TaskRepository {
 	return &TaskRepository{DB: db}
 }
 
-// method to insert a new row into postgreSQL
-func (r *TaskRepository) Create(task *models.Task) error {
+// WithSource returns a copy of the repository recording its changes as made by source
+func (r *TaskRepository) WithSource(source string) *TaskRepository {
+	copy := *r
+	copy.Source = source
+
+	return &copy
+}
+
+func (r *TaskRepository) source() string {
+	if r.Source == "" {
+		return models.SourceUser
+	}
+
+	return r.Source
+}
+
+// method to insert a new row into postgreSQL
+func (r *TaskRepository) Create(task *models.Task) error {
+	log.Printf("Executing task creation query: title=%s, userID=%d, status=%s, priority=%s\n",
+		task.Title, task.UserID, task.Status, task.Priority)
+
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	step, err := r.beginStep(tx, task.UserID, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if err := insertTask(tx, task); err != nil {
+		log.Printf("Database error during task creation: %v\n", err)
+		return err
+	}
+
+	//the first occurrence of a recurring task starts its series
+	if task.Recurrence != "" && task.SeriesID == nil {
+		if _, err := tx.Exec(`UPDATE tasks SET series_id = id WHERE id = $1`, task.ID); err != nil {
+			return err
+		}
+
+		task.SeriesID = &task.ID
+	}
+
+	if err := recordEvents(tx, task, r.source(), fieldChange{field: "created", new: textValue(task.Title)}); err != nil {
+		return err
+	}
+
+	//on create, Tags only need their IDs filled in
+	if len(task.Tags) > 0 {
+		tagIDs := make([]int, len(task.Tags))
+
+		for i, tag := range task.Tags {
+			tagIDs[i] = tag.ID
+		}
+
+		if task.Tags, err = setTags(tx, task.ID, task.UserID, tagIDs); err != nil {
+			return err
+		}
+	}
+
+	if err := r.endStep(tx, task.UserID, step, []int64{int64(task.ID)}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("Task created successfully in DB with ID: %d\n", task.ID)
+	return nil
+}
+
+// insertTask adds task to the database, a subtask goes after its existing siblings
+func insertTask(tx *sql.Tx, task *models.Task) error {
+	if task.ProjectID != nil {
+		if err := checkProject(tx, *task.ProjectID, task.UserID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO tasks (title, description, status, priority, due_date, user_id, parent_id, recurrence, series_id, auto_complete, project_id, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11,
+			CASE WHEN $7::int IS NULL THEN 0 ELSE (SELECT COALESCE(MAX(position) + 1, 0) FROM tasks WHERE parent_id = $7) END)
+		RETURNING id, position, created_at, updated_at
+	`
+
+	return tx.QueryRow(query,
+		task.Title,
+		task.Description,
+		task.Status,
+		task.Priority,
+		task.DueDate,
+		task.UserID,
+		task.ParentID,
+		task.Recurrence,
+		task.SeriesID,
+		task.AutoComplete,
+		task.ProjectID,
+	).Scan(&task.ID, &task.Position, &task.CreatedAt, &task.UpdatedAt)
+}
+
+// CreateSubtasks inserts children of parent in one transaction, all or nothing.
+// The subtasks get their IDs and timestamps filled in.
+func (r *TaskRepository) CreateSubtasks(parent *models.Task, subtasks []models.Task) error {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range subtasks {
+		subtask := &subtasks[i]
+		subtask.UserID = parent.UserID
+		subtask.ParentID = &parent.ID
+		subtask.ProjectID = parent.ProjectID
+
+		if err := insertTask(tx, subtask); err != nil {
+			return err
+		}
+
+		if err := recordEvents(tx, subtask, r.source(), fieldChange{field: "created", new: textValue(subtask.Title)}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// TaskFilter narrows down and orders List, the zero value matches every task, newest first.
+// Date ranges are half-open so that whole days can be given as midnight to midnight.
+type TaskFilter struct {
+	Tags            []string // tag names, ignoring case
+	AllTags         bool     // match tasks having every one of Tags instead of any
+	ProjectID       int      // only tasks in this project
+	Inbox           bool     // only tasks in no project
+	Statuses        []string
+	Priorities      []string
+	DueFrom         *time.Time // due on or after
+	DueBefore       *time.Time // due strictly before
+	CompletedFrom   *time.Time
+	CompletedBefore *time.Time
+	Overdue         bool   // only open tasks due before today
+	RootsOnly       bool   // only tasks that are not subtasks
+	Query           string // free text over title and description, web search syntax
+	Sort            string // one of TaskSorts
+	Desc            bool
+}
+
+// TaskSorts are the orders List supports
+var TaskSorts = []string{"created", "updated", "due", "completed", "priority", "title", "relevance"}
+
+// taskSorts maps each of TaskSorts to its SQL expression and type, the relevance
+// expression takes the placeholder of the search query
+var taskSorts = map[string]struct{ expr, cast string }{
+	"created":   {"created_at", "timestamp"},
+	"updated":   {"updated_at", "timestamp"},
+	"due":       {"due_date", "timestamp"},
+	"completed": {"completed_at", "timestamp"},
+	"priority":  {"CASE priority WHEN 'high' THEN 3 WHEN 'medium' THEN 2 WHEN 'low' THEN 1 ELSE 0 END", "int"},
+	"title":     {"LOWER(title)", "text"},
+	"relevance": {"ts_rank(search_vector, websearch_to_tsquery('english', %s))", "real"},
+}
+
+// method to get data of all the rows in our tasks table
+func (r *TaskRepository) GetAll(userID int) ([]models.Task, error) {
+	return r.List(userID, TaskFilter{})
+}
+
+// List returns all the user's tasks matching filter with their tags
+func (r *TaskRepository) List(userID int, filter TaskFilter) ([]models.Task, error) {
+	tasks, _, err := r.ListPage(userID, filter, nil, 0)
+
+	return tasks, err
+}
+
+// ListPage returns up to limit of the user's tasks matching filter, starting after
+// cursor (nil for the first page), and the cursor of the next page if there is one.
+// The cursor holds the sort key and id of the last task, so tasks added meanwhile
+// do not shift the pages. A limit of 0 returns every task.
+func (r *TaskRepository) ListPage(userID int, filter TaskFilter, cursor *Cursor, limit int) ([]models.Task, *Cursor, error) {
+	list, err := taskListQuery(userID, filter, cursor, limit)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := r.DB.Query(list.query, list.args...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	tasks := []models.Task{}
+	var keys []sql.NullString
+
+	for rows.Next() {
+		var sortKey sql.NullString
+
+		t, err := scanTask(extraScanner{rows, []any{&sortKey}})
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tasks = append(tasks, t)
+		keys = append(keys, sortKey)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+
+	if limit > 0 && len(tasks) > limit {
+		tasks = tasks[:limit]
+		last := tasks[limit-1]
+		next = &Cursor{Sort: list.sort, Desc: list.desc, ID: last.ID}
+
+		if keys[limit-1].Valid {
+			next.Value = &keys[limit-1].String
+		}
+	}
+
+	if err := r.attachTags(userID, tasks); err != nil {
+		return nil, nil, err
+	}
+
+	if err := r.attachProgress(userID, tasks); err != nil {
+		return nil, nil, err
+	}
+
+	return tasks, next, nil
+}
+
+// taskList is the query ListPage runs and the order it ends up in
+type taskList struct {
+	query string
+	args  []any
+	sort  string
+	desc  bool
+}
+
+// taskListQuery builds the query of ListPage, which selects the sort key as text after the task columns
+func taskListQuery(userID int, filter TaskFilter, cursor *Cursor, limit int) (*taskList, error) {
+	args := []any{userID}
+	where := []string{"user_id = $1", "deleted_at IS NULL"}
+
+	//arg adds a query argument and returns its placeholder
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, name := range filter.Tags {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	if len(names) > 0 {
+		placeholder := arg(pq.Array(names))
+
+		tagged := `(
+			SELECT COUNT(DISTINCT LOWER(g.name)) FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+			WHERE tt.task_id = tasks.id AND LOWER(g.name) = ANY(` + placeholder + `)
+		)`
+
+		if filter.AllTags {
+			where = append(where, tagged+` = cardinality(`+placeholder+`::text[])`)
+		} else {
+			where = append(where, tagged+` > 0`)
+		}
+	}
+
+	if filter.ProjectID != 0 {
+		where = append(where, "project_id = "+arg(filter.ProjectID))
+	} else if filter.Inbox {
+		where = append(where, "project_id IS NULL")
+	}
+
+	if filter.RootsOnly {
+		where = append(where, "parent_id IS NULL")
+	}
+
+	if len(filter.Statuses) > 0 {
+		where = append(where, "status = ANY("+arg(pq.Array(filter.Statuses))+")")
+	}
+
+	if len(filter.Priorities) > 0 {
+		where = append(where, "priority = ANY("+arg(pq.Array(filter.Priorities))+")")
+	}
+
+	if filter.DueFrom != nil {
+		where = append(where, "due_date >= "+arg(*filter.DueFrom))
+	}
+
+	if filter.DueBefore != nil {
+		where = append(where, "due_date < "+arg(*filter.DueBefore))
+	}
+
+	if filter.CompletedFrom != nil {
+		where = append(where, "completed_at >= "+arg(*filter.CompletedFrom))
+	}
+
+	if filter.CompletedBefore != nil {
+		where = append(where, "completed_at < "+arg(*filter.CompletedBefore))
+	}
+
+	if filter.Overdue {
+		where = append(where, "status <> 'complete' AND due_date < CURRENT_DATE")
+	}
+
+	var search string
+
+	if query := strings.TrimSpace(filter.Query); query != "" {
+		search = arg(query)
+		where = append(where, "search_vector @@ websearch_to_tsquery('english', "+search+")")
+	}
+
+	sort, desc := filter.Sort, filter.Desc
+
+	//no order given is best match first for a search, newest first otherwise
+	if sort == "" && search != "" {
+		sort, desc = "relevance", true
+	}
+
+	if _, ok := taskSorts[sort]; !ok || sort == "relevance" && search == "" {
+		sort, desc = "created", true
+	}
+
+	key := taskSorts[sort]
+
+	if sort == "relevance" {
+		key.expr = fmt.Sprintf(key.expr, search)
+	}
+
+	//a cursor only makes sense for the order it was made in
+	if cursor != nil {
+		if cursor.Sort != sort || cursor.Desc != desc || !cursor.valid(key.cast) {
+			return nil, ErrInvalidCursor
+		}
+
+		where = append(where, cursor.after(key.expr, key.cast, arg))
+	}
+
+	direction := "ASC"
+
+	if desc {
+		direction = "DESC"
+	}
+
+	//id breaks ties so the order is stable, undated tasks come last either way
+	query := `SELECT ` + taskColumns + `, (` + key.expr + `)::text FROM tasks WHERE ` + strings.Join(where, " AND ") +
+		` ORDER BY ` + key.expr + ` ` + direction + ` NULLS LAST, id ` + direction
+
+	//one extra row tells whether there is another page
+	if limit > 0 {
+		query += ` LIMIT ` + arg(limit+1)
+	}
+
+	return &taskList{query: query, args: args, sort: sort, desc: desc}, nil
+}
+
+// ListDescendants returns the subtasks, at any depth, of the given tasks with their tags
+func (r *TaskRepository) ListDescendants(userID int, ids []int) ([]models.Task, error) {
+	if len(ids) == 0 {
+		return []models.Task{}, nil
+	}
+
+	roots := make([]int64, len(ids))
+
+	for i, id := range ids {
+		roots[i] = int64(id)
+	}
+
+	query := `
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM tasks WHERE parent_id = ANY($2) AND user_id = $1
+			UNION ALL
+			SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id = s.id
+		)
+		SELECT ` + taskColumns + ` FROM tasks WHERE id IN (SELECT id FROM subtree) AND user_id = $1 AND deleted_at IS NULL
+		ORDER BY position, id
+	`
+
+	rows, err := r.DB.Query(query, userID, pq.Array(roots))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tasks := []models.Task{}
+
+	for rows.Next() {
+		t, err := scanTask(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.attachTags(userID, tasks); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// attachTags fills in the tags of tasks with one query
+func (r *TaskRepository) attachTags(userID int, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(tasks))
+	byID := make(map[int]*models.Task, len(tasks))
+
+	for i := range tasks {
+		ids[i] = int64(tasks[i].ID)
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	query := `
+		SELECT tt.task_id, g.id, g.name, g.color, g.created_at
+		FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+		WHERE g.user_id = $1 AND tt.task_id = ANY($2)
+		ORDER BY LOWER(g.name)
+	`
+
+	rows, err := r.DB.Query(query, userID, pq.Array(ids))
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID int
+		tag := models.Tag{UserID: userID}
+
+		if err := rows.Scan(&taskID, &tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt); err != nil {
+			return err
+		}
+
+		task := byID[taskID]
+		task.Tags = append(task.Tags, tag)
+	}
+
+	return rows.Err()
+}
+
+// attachProgress fills in the progress of the tasks that have subtasks, which
+// need not be among tasks
+func (r *TaskRepository) attachProgress(userID int, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(tasks))
+	byID := make(map[int]*models.Task, len(tasks))
+
+	for i := range tasks {
+		ids[i] = int64(tasks[i].ID)
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	query := `
+		SELECT parent_id, COUNT(*), COUNT(*) FILTER (WHERE status = 'complete')
+		FROM tasks
+		WHERE user_id = $1 AND parent_id = ANY($2) AND deleted_at IS NULL
+		GROUP BY parent_id
+	`
+
+	rows, err := r.DB.Query(query, userID, pq.Array(ids))
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var parentID int
+		var progress models.TaskProgress
+
+		if err := rows.Scan(&parentID, &progress.Total, &progress.Done); err != nil {
+			return err
+		}
+
+		progress.Percent = progress.Done * 100 / progress.Total
+		byID[parentID].Progress = &progress
+	}
+
+	return rows.Err()
+}
+
+// replaceTags swaps the tags of a locked task for tagIDs and notes the change,
+// every id must be one of the user's tags
+func replaceTags(tx *sql.Tx, task *models.Task, tagIDs []int, taskChanges *changes) error {
+	var old sql.NullString
+
+	query := `SELECT STRING_AGG(t.name, ', ' ORDER BY LOWER(t.name)) FROM task_tags tt JOIN tags t ON t.id = tt.tag_id WHERE tt.task_id = $1`
+
+	if err := tx.QueryRow(query, task.ID).Scan(&old); err != nil {
+		return err
+	}
+
+	tags, err := setTags(tx, task.ID, task.UserID, tagIDs)
+
+	if err != nil {
+		return err
+	}
+
+	task.Tags = tags
+	taskChanges.add("tags", textValue(old.String), tagsValue(tags))
+
+	return nil
+}
+
+func setTags(tx *sql.Tx, taskID, userID int, tagIDs []int) ([]models.Tag, error) {
+	ids := []int64{}
+	seen := map[int]bool{}
+
+	for _, id := range tagIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, int64(id))
+		}
+	}
+
+	rows, err := tx.Query(`SELECT id, name, color, created_at FROM tags WHERE user_id = $1 AND id = ANY($2) ORDER BY LOWER(name)`, userID, pq.Array(ids))
+
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []models.Tag{}
+
+	for rows.Next() {
+		tag := models.Tag{UserID: userID}
+
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(tags) != len(ids) {
+		return nil, ErrUnknownTag
+	}
+
+	if _, err := tx.Exec(`DELETE FROM task_tags WHERE task_id = $1`, taskID); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO task_tags (task_id, tag_id) SELECT $1, unnest($2::int[])`, taskID, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// Search returns the user's tasks matching a to_tsquery expression, best match first
+func (r *TaskRepository) Search(userID int, tsquery string, limit int) ([]models.Task, error) {
+	query := `
+		SELECT ` + taskColumns + ` FROM tasks
+		WHERE user_id = $1 AND deleted_at IS NULL AND search_vector @@ to_tsquery('english', $2)
+		ORDER BY ts_rank(search_vector, to_tsquery('english', $2)) DESC, updated_at DESC
+		LIMIT $3
+	`
+
+	rows, err := r.DB.Query(query, userID, tsquery, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tasks := []models.Task{}
+
+	for rows.Next() {
+		t, err := scanTask(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		t.UserID = userID
+		tasks = append(tasks, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+func (r *TaskRepository) GetByID(id, userID int) (*models.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
+
+	task, err := scanTask(r.DB.QueryRow(query, id, userID))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	task.UserID = userID
+
+	tasks := []models.Task{task}
+
+	if err := r.attachTags(userID, tasks); err != nil {
+		return nil, err
+	}
+
+	return &tasks[0], nil
+}
+
+// Delete moves a task to the trash, with its subtasks
+func (r *TaskRepository) Delete(id, userID int) error {
+	query := `
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+			UNION ALL
+			SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id = s.id WHERE t.deleted_at IS NULL
+		)
+		SELECT id FROM subtree
+	`
+
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids, err := queryIDs(tx, query, id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return ErrNotFound
+	}
+
+	step, err := r.beginStep(tx, userID, ids)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE tasks SET deleted_at = NOW(), updated_at = NOW() WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	if err := recordTrash(tx, userID, ids, "deleted", r.source()); err != nil {
+		return err
+	}
+
+	if err := r.endStep(tx, userID, step, ids); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// queryIDs runs a query selecting a single id column
+func queryIDs(tx *sql.Tx, query string, args ...any) ([]int64, error) {
+	rows, err := tx.Query(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// ListTrash returns the tasks in the trash, most recently deleted first. Subtasks
+// deleted along with their parent are not listed on their own.
+func (r *TaskRepository) ListTrash(userID int) ([]models.Task, error) {
+	query := `
+		SELECT ` + taskColumns + ` FROM tasks
+		WHERE user_id = $1 AND deleted_at IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM tasks p WHERE p.id = tasks.parent_id AND p.deleted_at IS NOT NULL)
+		ORDER BY deleted_at DESC, id DESC
+	`
+
+	rows, err := r.DB.Query(query, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tasks := []models.Task{}
+
+	for rows.Next() {
+		t, err := scanTask(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// Restore takes a task out of the trash, with the subtasks that were deleted along with it.
+// A subtask cannot be restored while its parent is still in the trash.
+func (r *TaskRepository) Restore(id, userID int) (*models.Task, error) {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	task, err := scanTask(tx.QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL FOR UPDATE`, id, userID))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if task.ParentID != nil {
+		var parentTrashed bool
+
+		if err := tx.QueryRow(`SELECT deleted_at IS NOT NULL FROM tasks WHERE id = $1`, *task.ParentID).Scan(&parentTrashed); err != nil {
+			return nil, err
+		}
+
+		if parentTrashed {
+			return nil, ErrParentTrashed
+		}
+	}
+
+	query := `
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM tasks WHERE id = $1
+			UNION ALL
+			SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id = s.id WHERE t.deleted_at = $2
+		)
+		SELECT id FROM subtree
+	`
+
+	ids, err := queryIDs(tx, query, task.ID, *task.DeletedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	step, err := r.beginStep(tx, userID, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`UPDATE tasks SET deleted_at = NULL, updated_at = NOW() WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	if err := recordTrash(tx, userID, ids, "restored", r.source()); err != nil {
+		return nil, err
+	}
+
+	if err := r.endStep(tx, userID, step, ids); err != nil {
+		return nil, err
+	}
+
+	if err := tx.QueryRow(`SELECT updated_at FROM tasks WHERE id = $1`, task.ID).Scan(&task.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	task.UserID = userID
+	task.DeletedAt = nil
+
+	return &task, nil
+}
+
+// DeletePermanently removes a task in the trash for good, its subtasks with it
+func (r *TaskRepository) DeletePermanently(id, userID int) error {
+	result, err := r.DB.Exec(`DELETE FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`, id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// EmptyTrash removes every task in the user's trash for good
+func (r *TaskRepository) EmptyTrash(userID int) (int64, error) {
+	result, err := r.DB.Exec(`DELETE FROM tasks WHERE user_id = $1 AND deleted_at IS NOT NULL`, userID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// PurgeTrash removes the tasks of every user that were put in the trash before cutoff
+func (r *TaskRepository) PurgeTrash(cutoff time.Time) (int64, error) {
+	result, err := r.DB.Exec(`DELETE FROM tasks WHERE deleted_at < $1`, cutoff)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// TaskUpdate holds the parts of a task an update may leave out, nil keeps them as they are
+type TaskUpdate struct {
+	Recurrence   *string // a normalized rule, "" stops the series
+	AutoComplete *bool
+	TagIDs       *[]int // every id must be one of the user's tags
+}
+
+// Update saves the editable fields of a task and the parts of extra that are given,
+// all in one transaction and as one undoable step. A new recurrence applies to the
+// occurrences generated from now on.
+func (r *TaskRepository) Update(task *models.Task, extra TaskUpdate) error {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := lockTask(tx, task.ID, task.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	touched := []int64{int64(task.ID)}
+
+	step, err := r.beginStep(tx, task.UserID, touched)
+
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE tasks SET title = $1, description = $2, status = $3, priority = $4, due_date = $5, updated_at = NOW()
+		WHERE id = $6 AND user_id = $7
+		RETURNING updated_at
+	`
+
+	err = tx.QueryRow(query,
+		task.Title,
+		task.Description,
+		task.Status,
+		task.Priority,
+		task.DueDate,
+		task.ID,
+		task.UserID,
+	).Scan(&task.UpdatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	var taskChanges changes
+	taskChanges.add("title", textValue(old.Title), textValue(task.Title))
+	taskChanges.add("description", textValue(old.Description), textValue(task.Description))
+	taskChanges.add("status", textValue(old.Status), textValue(task.Status))
+	taskChanges.add("priority", textValue(old.Priority), textValue(task.Priority))
+	taskChanges.add("due_date", dateValue(old.DueDate), dateValue(task.DueDate))
+
+	//the parts left out keep their values
+	task.Recurrence, task.SeriesID, task.AutoComplete = old.Recurrence, old.SeriesID, old.AutoComplete
+
+	if extra.Recurrence != nil {
+		query := `
+			UPDATE tasks SET
+				recurrence = NULLIF($1, ''),
+				series_id = CASE WHEN $1 = '' THEN series_id ELSE COALESCE(series_id, id) END
+			WHERE id = $2
+			RETURNING series_id
+		`
+
+		var series sql.NullInt64
+
+		if err := tx.QueryRow(query, *extra.Recurrence, task.ID).Scan(&series); err != nil {
+			return err
+		}
+
+		task.Recurrence, task.SeriesID = *extra.Recurrence, nil
+
+		if series.Valid {
+			seriesID := int(series.Int64)
+			task.SeriesID = &seriesID
+		}
+
+		taskChanges.add("recurrence", textValue(old.Recurrence), textValue(task.Recurrence))
+	}
+
+	if extra.AutoComplete != nil {
+		if _, err := tx.Exec(`UPDATE tasks SET auto_complete = $1 WHERE id = $2`, *extra.AutoComplete, task.ID); err != nil {
+			return err
+		}
+
+		task.AutoComplete = *extra.AutoComplete
+		taskChanges.add("auto_complete", boolValue(old.AutoComplete), boolValue(task.AutoComplete))
+	}
+
+	if extra.TagIDs != nil {
+		if err := replaceTags(tx, task, *extra.TagIDs, &taskChanges); err != nil {
+			return err
+		}
+	}
+
+	if err := recordEvents(tx, task, r.source(), taskChanges...); err != nil {
+		return err
+	}
+
+	if err := r.endStep(tx, task.UserID, step, touched); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateStatus sets a task's status. Completing an occurrence of a recurring task
+// schedules the next one; completing the same occurrence again, after reopening it,
+// does not schedule a second copy. A parent with auto_complete follows its subtasks:
+// it completes with the last of them and reopens when one of them does.
+func (r *TaskRepository) UpdateStatus(id int, status string, userID int) (*models.StatusChange, error) {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	task, err := lockTask(tx, id, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var touched []int64
+	var step *operationStep
+
+	//auto-completing parents change along with the task
+	if r.Operation != "" {
+		if touched, err = ancestry(tx, id, userID); err != nil {
+			return nil, err
+		}
+
+		if step, err = r.beginStep(tx, userID, touched); err != nil {
+			return nil, err
+		}
+	}
+
+	change := &models.StatusChange{}
+
+	if change.NextOccurrence, err = setStatus(tx, task, status, r.source()); err != nil {
+		return nil, err
+	}
+
+	for parentID := task.ParentID; parentID != nil; {
+		parent, err := lockTask(tx, *parentID, userID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !parent.AutoComplete {
+			break
+		}
+
+		var open int
+
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM tasks WHERE parent_id = $1 AND status <> 'complete' AND deleted_at IS NULL`, parent.ID).Scan(&open); err != nil {
+			return nil, err
+		}
+
+		parentStatus := parent.Status
+
+		if open == 0 {
+			parentStatus = "complete"
+		} else if parent.Status == "complete" {
+			parentStatus = "in_progress"
+		}
+
+		if parentStatus == parent.Status {
+			break
+		}
+
+		if _, err := setStatus(tx, parent, parentStatus, models.SourceSystem); err != nil {
+			return nil, err
+		}
+
+		parent.Status = parentStatus
+
+		//only the direct parent is reported, grandparents follow along silently
+		if change.Parent == nil {
+			change.Parent = parent
+		}
+
+		parentID = parent.ParentID
+	}
+
+	if change.NextOccurrence != nil {
+		touched = append(touched, int64(change.NextOccurrence.ID))
+	}
+
+	if err := r.endStep(tx, userID, step, touched); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// lockTask reads a task that is not in the trash for update within tx
+func lockTask(tx *sql.Tx, id, userID int) (*models.Task, error) {
+	task, err := scanTask(tx.QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE`, id, userID))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	task.UserID = userID
+
+	return &task, nil
+}
+
+// setStatus updates the status of a locked task, returning the next occurrence
+// if completing it scheduled one
+func setStatus(tx *sql.Tx, task *models.Task, status, source string) (*models.Task, error) {
+	var query string
+
+	if status == "complete" {
+		query = `UPDATE tasks SET status = $1, updated_at = NOW(), completed_at = NOW() WHERE id = $2 AND user_id = $3`
+	} else {
+		query = `UPDATE tasks SET status = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3`
+	}
+
+	if _, err := tx.Exec(query, status, task.ID, task.UserID); err != nil {
+		return nil, err
+	}
+
+	var statusChanges changes
+	statusChanges.add("status", textValue(task.Status), textValue(status))
+
+	if err := recordEvents(tx, task, source, statusChanges...); err != nil {
+		return nil, err
+	}
+
+	if status == "complete" && task.Status != "complete" && task.Recurrence != "" {
+		return scheduleNext(tx, task, time.Now())
+	}
+
+	return nil, nil
+}
+
+// scheduleNext inserts the occurrence of task's series that follows it, unless the
+// series already has one on that day
+func scheduleNext(tx *sql.Tx, task *models.Task, now time.Time) (*models.Task, error) {
+	rule, err := recurrence.Parse(task.Recurrence)
+
+	if err != nil {
+		return nil, err
+	}
+
+	//an undated occurrence counts as due the day it was done
+	due := now
+
+	if task.DueDate != nil {
+		due = *task.DueDate
+	}
+
+	nextDue := rule.NextOnOrAfter(due, now)
+
+	seriesID := task.ID
+
+	if task.SeriesID != nil {
+		seriesID = *task.SeriesID
+	}
+
+	var exists bool
+
+	query := `SELECT EXISTS (SELECT 1 FROM tasks WHERE user_id = $1 AND series_id = $2 AND DATE(due_date) = DATE($3) AND deleted_at IS NULL)`
+
+	if err := tx.QueryRow(query, task.UserID, seriesID, nextDue).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	if exists {
+		return nil, nil
+	}
+
+	next := models.Task{
+		UserID:      task.UserID,
+		ParentID:    task.ParentID,
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      "todo",
+		Priority:    task.Priority,
+		DueDate:     &nextDue,
+		Recurrence:  rule.String(),
+		SeriesID:    &seriesID,
+		ProjectID:   task.ProjectID,
+	}
+
+	if err := insertTask(tx, &next); err != nil {
+		return nil, err
+	}
+
+	if err := recordEvents(tx, &next, models.SourceSystem, fieldChange{field: "created", new: textValue(next.Title)}); err != nil {
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+// MoveToProject puts a task, with its subtasks, in a project; nil moves it to the inbox
+func (r *TaskRepository) MoveToProject(task *models.Task, projectID *int) error {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	locked, err := lockTask(tx, task.ID, task.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	if projectID != nil {
+		if err := checkProject(tx, *projectID, task.UserID); err != nil {
+			return err
+		}
+	}
+
 	query := `
-		INSERT INTO tasks (title, description, status, priority, due_date, user_id)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at, updated_at
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM tasks WHERE id = $1
+			UNION ALL
+			SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id = s.id
+		)
+		SELECT id FROM tasks WHERE id IN (SELECT id FROM subtree) AND user_id = $2 AND deleted_at IS NULL
 	`
-	log.Printf("Executing task creation query: title=%s, userID=%d, status=%s, priority=%s\n",
-		task.Title, task.UserID, task.Status, task.Priority)
 
-	err := r.DB.QueryRow(query,
-		task.Title,
-		task.Description,
-		task.Status,
-		task.Priority,
-		task.DueDate,
-		task.UserID,
-	).Scan(&task.ID, &task.CreatedAt, &task.UpdatedAt)
+	ids, err := queryIDs(tx, query, task.ID, task.UserID)
 
 	if err != nil {
-		log.Printf("Database error during task creation: %v\n", err)
 		return err
 	}
 
-	log.Printf("Task created successfully in DB with ID: %d\n", task.ID)
+	step, err := r.beginStep(tx, task.UserID, ids)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE tasks SET project_id = $1, updated_at = NOW() WHERE id = ANY($2)`, projectID, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	//subtasks follow their parent, only the task moved has it in its history
+	var projectChanges changes
+	projectChanges.add("project_id", idValue(locked.ProjectID), idValue(projectID))
+
+	if err := recordEvents(tx, locked, r.source(), projectChanges...); err != nil {
+		return err
+	}
+
+	if err := r.endStep(tx, task.UserID, step, ids); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	*task = *locked
+	task.ProjectID = projectID
+
 	return nil
 }
 
-// method to get data of all the rows in our tasks table
-func (r *TaskRepository) GetAll(userID int) ([]models.Task, error) {
-	query := `SELECT id, title, description, status, priority, due_date, completed_at, created_at, updated_at FROM tasks WHERE user_id = $1 ORDER BY id DESC`
+// checkProject makes sure projectID is one of the user's projects
+func checkProject(tx *sql.Tx, projectID, userID int) error {
+	var exists bool
 
-	rows, err := r.DB.Query(query, userID)
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1 AND user_id = $2)`, projectID, userID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrUnknownProject
+	}
+
+	return nil
+}
+
+// GetSubtasks returns the direct subtasks of a task in their manual order
+func (r *TaskRepository) GetSubtasks(parentID, userID int) ([]models.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE parent_id = $1 AND user_id = $2 AND deleted_at IS NULL ORDER BY position, id`
+
+	rows, err := r.DB.Query(query, parentID, userID)
 
 	if err != nil {
 		return nil, err
 	}
+
 	defer rows.Close()
 
-	var tasks []models.Task
+	tasks := []models.Task{}
+
 	for rows.Next() {
-		var t models.Task
-		var due sql.NullTime
-		var completed sql.NullTime
+		t, err := scanTask(rows)
 
-		if err := rows.Scan(&t.ID, &t.Title, &t.Description, &t.Status, &t.Priority, &due, &completed, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		if err != nil {
 			return nil, err
 		}
 
-		if due.Valid {
-			t.DueDate = &due.Time
+		tasks = append(tasks, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// ReorderSubtasks puts the subtasks of a task in the order of ids, which must list
+// each of them exactly once
+func (r *TaskRepository) ReorderSubtasks(parentID, userID int, ids []int) error {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockTask(tx, parentID, userID); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT id FROM tasks WHERE parent_id = $1 AND user_id = $2 AND deleted_at IS NULL`, parentID, userID)
+
+	if err != nil {
+		return err
+	}
+
+	current := map[int]bool{}
+	touched := []int64{}
+
+	for rows.Next() {
+		var id int
+
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+
+		current[id] = true
+		touched = append(touched, int64(id))
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(ids) != len(current) {
+		return ErrSubtaskOrder
+	}
+
+	step, err := r.beginStep(tx, userID, touched)
+
+	if err != nil {
+		return err
+	}
+
+	for position, id := range ids {
+		if !current[id] {
+			return ErrSubtaskOrder
+		}
+
+		//a duplicate id would leave another subtask out
+		delete(current, id)
+
+		if _, err := tx.Exec(`UPDATE tasks SET position = $1, updated_at = NOW() WHERE id = $2`, position, id); err != nil {
+			return err
 		}
+	}
+
+	if err := r.endStep(tx, userID, step, touched); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetSeries returns the occurrences of a recurring task, oldest first
+func (r *TaskRepository) GetSeries(userID, seriesID int) ([]models.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE user_id = $1 AND series_id = $2 AND deleted_at IS NULL ORDER BY due_date NULLS FIRST, id`
+
+	rows, err := r.DB.Query(query, userID, seriesID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
 
-		if completed.Valid {
-			t.CompletedAt = &completed.Time
+	tasks := []models.Task{}
+
+	for rows.Next() {
+		t, err := scanTask(rows)
+
+		if err != nil {
+			return nil, err
 		}
 
 		tasks = append(tasks, t)
@@ -84,63 +1508,122 @@ func (r *TaskRepository) GetAll(userID int) ([]models.Task, error) {
 	return tasks, nil
 }
 
-func (r *TaskRepository) Delete(id, userID int) error {
-	query := `DELETE FROM tasks WHERE id = $1 AND user_id = $2`
-
-	_, err := r.DB.Exec(query, id, userID)
+// Reschedule moves a task to a new due date, nil clears it
+func (r *TaskRepository) Reschedule(id, userID int, dueDate *time.Time) error {
+	tx, err := r.DB.Begin()
 
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	if err := r.reschedule(tx, id, userID, dueDate); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *TaskRepository) Update(task *models.Task) error {
-	query := `
-		UPDATE tasks SET title = $1, description = $2, status = $3, priority = $4, due_date = $5, updated_at = NOW()
-		WHERE id = $6 AND user_id = $7
-		RETURNING updated_at
-	`
-	return r.DB.QueryRow(query,
-		task.Title,
-		task.Description,
-		task.Status,
-		task.Priority,
-		task.DueDate,
-		task.ID,
-		task.UserID,
-	).Scan(&task.UpdatedAt)
+// ApplyPlan accepts a plan and moves its deferred tasks to their new due dates, all in
+// one transaction; accepting twice gives ErrNotFound. Returns how many tasks were moved
+// and the ids of those skipped: deleted since the plan was made, or given a date that
+// does not parse.
+func (r *TaskRepository) ApplyPlan(plan *models.DailyPlan) (int, []int, error) {
+	tx, err := r.DB.Begin()
+
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE daily_plans SET accepted_at = NOW() WHERE id = $1 AND user_id = $2 AND accepted_at IS NULL RETURNING accepted_at`
+
+	err = tx.QueryRow(query, plan.ID, plan.UserID).Scan(&plan.AcceptedAt)
+
+	if err == sql.ErrNoRows {
+		return 0, nil, ErrNotFound
+	}
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rescheduled := 0
+	skipped := []int{}
+
+	for _, deferral := range plan.Deferrals {
+		newDueDate, err := time.Parse("2006-01-02", deferral.NewDueDate)
+
+		if err != nil {
+			skipped = append(skipped, deferral.TaskID)
+			continue
+		}
+
+		err = r.reschedule(tx, deferral.TaskID, plan.UserID, &newDueDate)
+
+		if errors.Is(err, ErrNotFound) {
+			skipped = append(skipped, deferral.TaskID)
+			continue
+		}
+
+		if err != nil {
+			return 0, nil, err
+		}
+
+		rescheduled++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+
+	return rescheduled, skipped, nil
 }
 
-func (r *TaskRepository) UpdateStatus(id int, status string, userID int) error {
-	var query string
+// reschedule moves a task to a new due date within tx
+func (r *TaskRepository) reschedule(tx *sql.Tx, id, userID int, dueDate *time.Time) error {
+	task, err := lockTask(tx, id, userID)
 
-	if status == "complete" {
-		query = `UPDATE tasks SET status = $1, updated_at = NOW(), completed_at = NOW() WHERE id = $2 AND user_id = $3`
-	} else {
-		query = `UPDATE tasks SET status = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3`
+	if err != nil {
+		return err
 	}
 
-	_, err := r.DB.Exec(query, status, id, userID)
+	if _, err := tx.Exec(`UPDATE tasks SET due_date = $1, updated_at = NOW() WHERE id = $2`, dueDate, id); err != nil {
+		return err
+	}
 
-	return err
+	var dueChanges changes
+	dueChanges.add("due_date", dateValue(task.DueDate), dateValue(dueDate))
+
+	return recordEvents(tx, task, r.source(), dueChanges...)
 }
 
 // GetMonthlyHeatmapData returns daily task completion counts for the last 28 days
 func (r *TaskRepository) GetMonthlyHeatmapData(userID int) (map[string]int, error) {
+	return r.heatmapData(userID, 0)
+}
+
+// GetSeriesHeatmapData is GetMonthlyHeatmapData for the occurrences of one recurring task
+func (r *TaskRepository) GetSeriesHeatmapData(userID, seriesID int) (map[string]int, error) {
+	return r.heatmapData(userID, seriesID)
+}
+
+// heatmapData counts completions per day over the last 28 days, of one series unless seriesID is 0
+func (r *TaskRepository) heatmapData(userID, seriesID int) (map[string]int, error) {
 	// Get data for the last 28 days
 	query := `
 		SELECT DATE(completed_at) as completion_date, COUNT(*) as task_count
 		FROM tasks
 		WHERE user_id = $1 
+		  AND deleted_at IS NULL
+		  AND ($2 = 0 OR series_id = $2)
 		  AND completed_at IS NOT NULL
 		  AND completed_at >= NOW() - INTERVAL '28 days'
 		GROUP BY DATE(completed_at)
 		ORDER BY DATE(completed_at) DESC
 	`
 
-	rows, err := r.DB.Query(query, userID)
+	rows, err := r.DB.Query(query, userID, seriesID)
 	if err != nil {
 		return nil, err
 	}
@@ -173,7 +1656,7 @@ func (r *TaskRepository) GetCurrentStreaks(userID int) (int, error) {
 	query := `
 		SELECT DATE(completed_at), COUNT(*)
 		FROM tasks
-		WHERE completed_at IS NOT NULL AND completed_at <= due_date AND user_id = $1
+		WHERE completed_at IS NOT NULL AND completed_at <= due_date AND user_id = $1 AND deleted_at IS NULL
 		GROUP BY DATE(completed_at)
 		ORDER BY DATE(completed_at) DESC;
 	`
@@ -213,3 +1696,50 @@ func (r *TaskRepository) GetCurrentStreaks(userID int) (int, error) {
 
 	return streak, nil
 }
+
+// GetSeriesStreak counts the occurrences of a recurring task done on or before their
+// due day, back from the latest one. An open occurrence that is not overdue yet
+// does not break the streak.
+func (r *TaskRepository) GetSeriesStreak(userID, seriesID int) (int, error) {
+	query := `
+		SELECT DATE(due_date), DATE(completed_at) FROM tasks
+		WHERE user_id = $1 AND series_id = $2 AND due_date IS NOT NULL AND deleted_at IS NULL
+		ORDER BY due_date DESC
+	`
+
+	rows, err := r.DB.Query(query, userID, seriesID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	today := time.Now().Format("2006-01-02")
+	streak := 0
+
+	for rows.Next() {
+		var due time.Time
+		var completed sql.NullTime
+
+		if err := rows.Scan(&due, &completed); err != nil {
+			return 0, err
+		}
+
+		if !completed.Valid {
+			if due.Format("2006-01-02") >= today {
+				continue
+			}
+
+			break
+		}
+
+		if completed.Time.After(due) {
+			break
+		}
+
+		streak++
+	}
+
+	return streak, rows.Err()
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+type UsageRepository struct {
+	DB *sql.DB
+}
+
+func NewUsageRepository(db *sql.DB) *UsageRepository {
+	return &UsageRepository{DB: db}
+}
+
+// Record stores one model call made for the user
+func (r *UsageRepository) Record(userID int, feature string, promptTokens, responseTokens int) error {
+	query := `INSERT INTO ai_usage (user_id, feature, prompt_tokens, response_tokens) VALUES ($1, $2, $3, $4)`
+
+	_, err := r.DB.Exec(query, userID, feature, promptTokens, responseTokens)
+
+	return err
+}
+
+// Totals adds up the user's model calls since the given time
+func (r *UsageRepository) Totals(userID int, since time.Time) (models.UsageTotals, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(response_tokens), 0)
+		FROM ai_usage
+		WHERE user_id = $1 AND created_at >= $2
+	`
+
+	var totals models.UsageTotals
+
+	err := r.DB.QueryRow(query, userID, since).Scan(&totals.Requests, &totals.PromptTokens, &totals.ResponseTokens)
+	totals.TotalTokens = totals.PromptTokens + totals.ResponseTokens
+
+	return totals, err
+}
+
+// TotalsByFeature is Totals split by the feature that made the calls
+func (r *UsageRepository) TotalsByFeature(userID int, since time.Time) (map[string]models.UsageTotals, error) {
+	query := `
+		SELECT feature, COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(response_tokens), 0)
+		FROM ai_usage
+		WHERE user_id = $1 AND created_at >= $2
+		GROUP BY feature
+	`
+
+	rows, err := r.DB.Query(query, userID, since)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	byFeature := map[string]models.UsageTotals{}
+
+	for rows.Next() {
+		var feature string
+		var totals models.UsageTotals
+
+		if err := rows.Scan(&feature, &totals.Requests, &totals.PromptTokens, &totals.ResponseTokens); err != nil {
+			return nil, err
+		}
+
+		totals.TotalTokens = totals.PromptTokens + totals.ResponseTokens
+		byFeature[feature] = totals
+	}
+
+	return byFeature, rows.Err()
+}
+
+// PerUser adds up the usage of every user in [from, to), heaviest users first
+func (r *UsageRepository) PerUser(from, to time.Time) ([]models.UserUsageSummary, error) {
+	query := `
+		SELECT u.id, u.email, COUNT(*), COALESCE(SUM(a.prompt_tokens), 0), COALESCE(SUM(a.response_tokens), 0)
+		FROM ai_usage a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.created_at >= $1 AND a.created_at < $2
+		GROUP BY u.id, u.email
+		ORDER BY SUM(a.prompt_tokens + a.response_tokens) DESC, u.id
+	`
+
+	rows, err := r.DB.Query(query, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []models.UserUsageSummary{}
+
+	for rows.Next() {
+		var user models.UserUsageSummary
+
+		if err := rows.Scan(&user.UserID, &user.Email, &user.Requests, &user.PromptTokens, &user.ResponseTokens); err != nil {
+			return nil, err
+		}
+
+		user.TotalTokens = user.PromptTokens + user.ResponseTokens
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
@@ -52,3 +52,34 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	return &user, nil
 
 }
+
+func (r *UserRepository) GetMentorPersona(userID int) (string, error) {
+	var persona string
+
+	err := r.DB.QueryRow(`SELECT mentor_persona FROM users WHERE id = $1`, userID).Scan(&persona)
+
+	if err == sql.ErrNoRows {
+		return "", errors.New("user not found")
+	}
+
+	return persona, err
+}
+
+func (r *UserRepository) SetMentorPersona(userID int, persona string) error {
+	_, err := r.DB.Exec(`UPDATE users SET mentor_persona = $1, updated_at = NOW() WHERE id = $2`, persona, userID)
+
+	return err
+}
+
+// IsAdmin reports whether the user may see the admin endpoints
+func (r *UserRepository) IsAdmin(userID int) (bool, error) {
+	var isAdmin bool
+
+	err := r.DB.QueryRow(`SELECT is_admin FROM users WHERE id = $1`, userID).Scan(&isAdmin)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	return isAdmin, err
+}
@@ -2,192 +2,397 @@ package service
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
-	"os"
 	"time"
 
+	"github.com/Philip-Machar/clario/internal/llm"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/prompts"
 	"github.com/Philip-Machar/clario/internal/repository"
-	"github.com/google/generative-ai-go/genai"
-	"google.golang.org/api/option"
 )
 
+var ErrThreadArchived = errors.New("thread is archived")
+
+const defaultThreadTitle = "General"
+
 type AIService struct {
-	Client   *genai.Client
-	Model    *genai.GenerativeModel
-	ChatRepo *repository.ChatRepository
-	TaskRepo *repository.TaskRepository
+	Model      llm.MentorModel
+	ChatRepo   *repository.ChatRepository
+	TaskRepo   *repository.TaskRepository
+	ActionRepo *repository.ActionRepository
+	MemoryRepo *repository.MemoryRepository
+	UserRepo   *repository.UserRepository
+	PlanRepo   *repository.PlanRepository
+	ReviewRepo *repository.ReviewRepository
+	NudgeRepo  *repository.NudgeRepository
+	Summarizer *Summarizer
+	Prompts    *prompts.Renderer
+	Usage      *UsageMeter
 }
 
-func NewAIService(chatRepo *repository.ChatRepository, taskRepo *repository.TaskRepository) *AIService {
-	ctx := context.Background()
-	apiKey := os.Getenv("GEMINI_API_KEY")
+func NewAIService(
+	model llm.MentorModel,
+	chatRepo *repository.ChatRepository,
+	taskRepo *repository.TaskRepository,
+	actionRepo *repository.ActionRepository,
+	memoryRepo *repository.MemoryRepository,
+	userRepo *repository.UserRepository,
+	planRepo *repository.PlanRepository,
+	reviewRepo *repository.ReviewRepository,
+	nudgeRepo *repository.NudgeRepository,
+	summarizer *Summarizer,
+	renderer *prompts.Renderer,
+	usage *UsageMeter,
+) *AIService {
+	return &AIService{
+		Model:      model,
+		ChatRepo:   chatRepo,
+		TaskRepo:   taskRepo,
+		ActionRepo: actionRepo,
+		MemoryRepo: memoryRepo,
+		UserRepo:   userRepo,
+		PlanRepo:   planRepo,
+		ReviewRepo: reviewRepo,
+		NudgeRepo:  nudgeRepo,
+		Summarizer: summarizer,
+		Prompts:    renderer,
+		Usage:      usage,
+	}
+}
 
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+// ResolveThread picks the thread a chat message belongs to. A zero threadID means the
+// user's most recently active thread, which is created on first use.
+func (s *AIService) ResolveThread(userID, threadID int) (int, error) {
+	threadID, err := s.LookupThread(userID, threadID)
 
-	if err != nil {
-		log.Fatal(err)
+	if err != nil || threadID != 0 {
+		return threadID, err
 	}
 
-	model := client.GenerativeModel("gemini-2.5-flash")
+	thread := &models.ChatThread{UserID: userID, Title: defaultThreadTitle}
 
-	return &AIService{
-		Client:   client,
-		Model:    model,
-		ChatRepo: chatRepo,
-		TaskRepo: taskRepo,
+	if err := s.ChatRepo.CreateThread(thread); err != nil {
+		return 0, err
 	}
+
+	return thread.ID, nil
 }
 
-func (s *AIService) GetMentorResponse(ctx context.Context, userID int, userMessage string) (string, error) {
-	//get all user tasks for context
-	allTasks, _ := s.TaskRepo.GetAll(userID)
+// LookupThread picks a thread like ResolveThread but never creates one, it returns 0
+// when the user has no thread yet
+func (s *AIService) LookupThread(userID, threadID int) (int, error) {
+	if threadID != 0 {
+		thread, err := s.ChatRepo.GetThread(threadID, userID)
 
-	todayTotalTasks := 0
-	todayDoneTasks := 0
-	overdueTasks := 0
+		if err != nil {
+			return 0, err
+		}
 
-	taskReport := ""
+		if thread.Archived {
+			return 0, ErrThreadArchived
+		}
 
-	now := time.Now()
-	todayDateStr := now.Format("2006-01-02")
+		return thread.ID, nil
+	}
 
-	for _, task := range allTasks {
-		if task.DueDate == nil {
-			continue
+	thread, err := s.ChatRepo.GetLatestThread(userID)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return thread.ID, nil
+}
+
+// GetMentorResponse answers a chat message. The model may call mentorTools along the way;
+// those run server-side for userID and are returned as the actions taken.
+// If the model cannot be reached the reply is a canned one built from the task stats.
+func (s *AIService) GetMentorResponse(ctx context.Context, userID int, threadID int, userMessage string) (models.ChatResponse, error) {
+	response := models.ChatResponse{ThreadID: threadID, Actions: []models.MentorAction{}}
+
+	if err := s.Usage.Check(userID); err != nil {
+		return response, err
+	}
+
+	req, err := s.buildMentorRequest(userID, threadID, userMessage)
+
+	if err != nil {
+		return response, err
+	}
+
+	req.Tools = mentorTools
+
+	for round := 0; ; round++ {
+		//out of rounds, the model has to answer in words now
+		if round == maxToolRounds {
+			req.Tools = nil
 		}
 
-		taskDateStr := task.DueDate.Format("2006-01-02")
+		reply, err := s.generate(ctx, userID, FeatureChat, req)
 
-		// Case A: Due Today
-		if taskDateStr == todayDateStr {
-			todayTotalTasks++
-			status := "PENDING"
-			if task.Status == "complete" {
-				todayDoneTasks++
-				status = "DONE"
+		if err != nil {
+			if ctx.Err() != nil {
+				return response, err
 			}
 
-			taskReport += fmt.Sprintf("- Status: %s, Title: %s, Description: %s, Priority: %s\n", status, task.Title, task.Description, task.Priority)
+			log.Printf("Mentor model failed for user %d, sending fallback reply: %v\n", userID, err)
+
+			response.Response = s.fallbackReply(userID, response.Actions)
+			response.Fallback = true
+
+			return response, nil
 		}
 
-		// Case B: Overdue
-		if task.DueDate.Before(now) && taskDateStr != todayDateStr && task.Status != "complete" {
-			overdueTasks++
-			taskReport += fmt.Sprintf("- OVERDUE: %s Due: %s\n", task.Title, taskDateStr)
+		if len(reply.ToolCalls) == 0 {
+			response.Response = reply.Text
+
+			if response.Response == "" && len(response.Actions) > 0 {
+				response.Response = actionsSummary(response.Actions)
+			}
+
+			s.saveExchange(userID, threadID, userMessage, response.Response)
+
+			return response, nil
+		}
+
+		//the prompt becomes history, followed by the model's calls and their results
+		if req.Prompt != "" {
+			req.History = append(req.History, llm.Message{Role: "user", Content: req.Prompt})
+			req.Prompt = ""
+		}
+
+		req.History = append(req.History, llm.Message{Role: "assistant", Content: reply.Text, ToolCalls: reply.ToolCalls})
+
+		results := make([]llm.ToolResult, 0, len(reply.ToolCalls))
+
+		for _, call := range reply.ToolCalls {
+			action := s.runToolCall(userID, call)
+			response.Actions = append(response.Actions, action)
+			results = append(results, toolResult(call, action))
 		}
+
+		req.History = append(req.History, llm.Message{Role: "tool", ToolResults: results})
 	}
+}
 
-	// system prompt
-	systemPrompt := fmt.Sprintf(`
-	You are an AI mentor, accountability partner, and systems coach.
-
-	Your job is NOT to be polite or motivational only.
-	Your job is to help the user actually become the person they say they want to be.
-
-	You have full access to their task data and recent behavior.
-	You must use it.
-
-	CORE ROLE:
-	- Act like a calm, honest mentor who genuinely wants the user to win.
-	- Encourage progress, effort, and honesty.
-	- Call out self-sabotage, inconsistency, avoidance, and excuses when you see patterns.
-	- Do NOT sugar-coat repeated failures.
-	- Be firm but respectful. Honest, not harsh.
-
-	PHILOSOPHY YOU MUST PUSH:
-	- Motivation is unreliable. Systems beat motivation.
-	- Identity drives behavior ("What would a disciplined person do today?")
-	- Small consistent actions matter more than perfect plans.
-	- Missed tasks are data, not shame — but patterns must be addressed.
-	- The goal is not task completion, it is becoming a consistent person.
-
-	HOW TO USE TASK DATA:
-	- Explicitly reference:
-	- Tasks due today
-	- Tasks completed today
-	- Overdue tasks
-	- If tasks are repeatedly overdue, point it out clearly.
-	- If today’s completion rate is low, address it directly.
-	- If progress is good, acknowledge it and reinforce the identity behind it.
-	- Ask WHY tasks are not getting done, but do not accept vague answers like “I was busy” without pushing deeper.
-
-	WHEN YOU SEE PROBLEMS:
-	- Identify the pattern (e.g. overloading days, avoidance, poor prioritization).
-	- Call it out clearly.
-	- Propose concrete systems:
-	- Fewer daily tasks
-	- Time-blocking
-	- Non-negotiable minimums
-	- Environment changes
-	- Breaking tasks into smaller steps
-	- Push the user to change the system, not rely on willpower.
-
-	TONE & STYLE:
-	- Supportive, grounded, direct.
-	- Speak like a real mentor, not a therapist.
-	- You may challenge the user respectfully.
-	- Avoid generic motivational quotes.
-	- Avoid robotic language.
-	- Be human.
-
-	IDENTITY REINFORCEMENT:
-	- Regularly remind the user:
-	- “This is about becoming someone who follows through.”
-	- “Each action is a vote for the person you want to be.”
-	- When they act in alignment, name it explicitly.
-	- When they don’t, point out the gap between identity and action.
-
-	IMPORTANT:
-	- Do not shame.
-	- Do not enable excuses.
-	- Do not pretend everything is fine when it isn’t.
-	- Always aim to move the user one step closer to consistency today.
-
-	USER STATS (use these explicitly in your response):
-	- Tasks Due Today: %d
-	- Completed Today: %d
-	- Overdue Tasks: %d
-
-	TASK DETAILS:
-	%s
-
-	be concise and to the point two to three sentences max
-	`, todayTotalTasks, todayDoneTasks, overdueTasks, taskReport)
-
-	chatHistory, _ := s.ChatRepo.GetRecentHistory(userID)
-
-	//start chat session
-	chatSession := s.Model.StartChat()
+// StreamMentorResponse streams the mentor reply through onChunk and saves the
+// exchange once the model has finished. Nothing is saved if the stream is cut
+// short, e.g. because the client went away and ctx was cancelled.
+// If the model fails before sending anything, the fallback reply is streamed instead.
+func (s *AIService) StreamMentorResponse(ctx context.Context, userID int, threadID int, userMessage string, onChunk llm.ChunkFunc) (models.ChatResponse, error) {
+	response := models.ChatResponse{ThreadID: threadID}
 
-	for _, message := range chatHistory {
-		role := "user"
+	if err := s.Usage.Check(userID); err != nil {
+		return response, err
+	}
+
+	req, err := s.buildMentorRequest(userID, threadID, userMessage)
+
+	if err != nil {
+		return response, err
+	}
 
-		if message.Role == "assistant" {
-			role = "model"
+	started := false
+
+	reply, err := s.stream(ctx, userID, FeatureChat, req, func(text string) error {
+		started = true
+		return onChunk(text)
+	})
+
+	if err != nil {
+		if started || ctx.Err() != nil {
+			return response, err
 		}
 
-		chatSession.History = append(chatSession.History, &genai.Content{Role: role, Parts: []genai.Part{genai.Text(message.Message)}})
+		log.Printf("Mentor model failed for user %d, sending fallback reply: %v\n", userID, err)
+
+		response.Response = s.fallbackReply(userID, nil)
+		response.Fallback = true
+
+		return response, onChunk(response.Response)
+	}
+
+	response.Response = reply.Text
+
+	s.saveExchange(userID, threadID, userMessage, reply.Text)
+
+	return response, nil
+}
+
+// generate calls the model and records what the call cost the user
+func (s *AIService) generate(ctx context.Context, userID int, feature string, req *llm.Request) (*llm.Reply, error) {
+	reply, err := s.Model.Generate(ctx, req)
+
+	if err == nil {
+		s.Usage.Record(userID, feature, reply.Usage)
+	}
+
+	return reply, err
+}
+
+// stream is generate for streamed replies
+func (s *AIService) stream(ctx context.Context, userID int, feature string, req *llm.Request, onChunk llm.ChunkFunc) (*llm.Reply, error) {
+	reply, err := s.Model.Stream(ctx, req, onChunk)
+
+	if err == nil {
+		s.Usage.Record(userID, feature, reply.Usage)
 	}
 
-	//send message
-	finalPrompt := systemPrompt + "\n\nUser: " + userMessage
+	return reply, err
+}
 
-	response, err := chatSession.SendMessage(ctx, genai.Text(finalPrompt))
+// buildMentorRequest puts together the system prompt, task context and the recent history of the thread
+func (s *AIService) buildMentorRequest(userID int, threadID int, userMessage string) (*llm.Request, error) {
+	prompt, chatHistory, err := s.renderMentorPrompt(userID, threadID, userMessage)
 
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	history := make([]llm.Message, 0, len(chatHistory))
+
+	for _, message := range chatHistory {
+		history = append(history, llm.Message{Role: message.Role, Content: message.Message})
+	}
+
+	return &llm.Request{History: history, Prompt: prompt}, nil
+}
+
+// PreviewPrompt renders the prompt the mentor would send for userMessage, without calling the model
+func (s *AIService) PreviewPrompt(userID int, threadID int, userMessage string) (string, string, error) {
+	prompt, _, err := s.renderMentorPrompt(userID, threadID, userMessage)
+
+	return s.persona(userID), prompt, err
+}
+
+// renderMentorPrompt renders the prompt for the user's persona and returns it with the raw history to replay
+func (s *AIService) renderMentorPrompt(userID int, threadID int, userMessage string) (string, []models.ChatMessage, error) {
+	//get all user tasks for context
+	allTasks, _ := s.TaskRepo.GetAll(userID)
+
+	//older messages come back as a summary, recent ones verbatim, within the token budget
+	summary, chatHistory := s.Summarizer.History(userID, threadID)
+
+	//long-term memories, MentorPrompt keeps the ones relevant to this message
+	memories, _ := s.MemoryRepo.List(userID, "")
+
+	//past tasks and conversations that match the message
+	relatedTasks, relatedMessages := s.retrieve(userID, userMessage, chatHistory)
+
+	//how often each task was postponed, from its history
+	reschedules, _ := s.TaskRepo.RescheduleCounts(userID)
+
+	prompt, err := MentorPrompt(s.Prompts, s.persona(userID), MentorInput{
+		Tasks:           allTasks,
+		Reschedules:     reschedules,
+		Memories:        memories,
+		Summary:         summary,
+		RelatedTasks:    relatedTasks,
+		RelatedMessages: relatedMessages,
+		UserMessage:     userMessage,
+		Now:             time.Now(),
+	})
+
+	return prompt, chatHistory, err
+}
+
+// MentorInput is everything the mentor prompt is rendered from
+type MentorInput struct {
+	Tasks           []models.Task
+	Reschedules     map[int]int // task id to times its due date was pushed back
+	Memories        []models.MentorMemory
+	Summary         string
+	RelatedTasks    []models.Task        // found by retrieval
+	RelatedMessages []models.ChatMessage // found by retrieval
+	UserMessage     string
+	Now             time.Time
+}
+
+// MentorPrompt renders the mentor prompt exactly as chat sends it, without touching
+// the database, so offline evaluations see the same prompt as users do
+func MentorPrompt(renderer *prompts.Renderer, persona string, input MentorInput) (string, error) {
+	data := MentorPromptData(input.Tasks, input.Now)
+
+	for _, lines := range [][]prompts.TaskLine{data.TodayTasks, data.OverdueTasks} {
+		for i := range lines {
+			lines[i].Rescheduled = input.Reschedules[lines[i].ID]
+		}
 	}
 
-	//extract ai reply
-	aiResponse := ""
-	if len(response.Candidates) > 0 && len(response.Candidates[0].Content.Parts) > 0 {
-		aiResponse = fmt.Sprintf("%s", response.Candidates[0].Content.Parts[0])
+	data.UserMessage = input.UserMessage
+	data.Summary = input.Summary
+	data.Memories = selectMemories(input.Memories, input.UserMessage)
+	data.RelatedTasks, data.RelatedMessages = relatedLines(data, input.RelatedTasks, input.RelatedMessages)
+
+	return renderer.RenderMentor(persona, data)
+}
+
+// persona returns the persona the user picked, or the default one if it no longer exists
+func (s *AIService) persona(userID int) string {
+	persona, err := s.UserRepo.GetMentorPersona(userID)
+
+	if err != nil || !s.Prompts.HasPersona(persona) {
+		return prompts.DefaultPersona
 	}
 
-	//save current chat to db
-	s.ChatRepo.SaveMessage(userID, "user", userMessage)
-	s.ChatRepo.SaveMessage(userID, "assistant", aiResponse)
+	return persona
+}
+
+// MentorPromptData works out the task part of the mentor prompt: what is due today and what is overdue
+func MentorPromptData(allTasks []models.Task, now time.Time) prompts.MentorData {
+	data := prompts.MentorData{Today: now.Format("2006-01-02")}
+
+	for _, task := range allTasks {
+		if task.DueDate == nil {
+			continue
+		}
+
+		taskDateStr := task.DueDate.Format("2006-01-02")
+
+		// Case A: Due Today
+		if taskDateStr == data.Today {
+			data.Stats.DueToday++
+			done := task.Status == "complete"
+
+			if done {
+				data.Stats.CompletedToday++
+			}
+
+			data.TodayTasks = append(data.TodayTasks, prompts.TaskLine{
+				ID:          task.ID,
+				Title:       task.Title,
+				Description: task.Description,
+				Priority:    task.Priority,
+				Done:        done,
+				Due:         taskDateStr,
+			})
+		}
+
+		// Case B: Overdue
+		if task.DueDate.Before(now) && taskDateStr != data.Today && task.Status != "complete" {
+			data.Stats.Overdue++
+			data.OverdueTasks = append(data.OverdueTasks, prompts.TaskLine{
+				ID:       task.ID,
+				Title:    task.Title,
+				Priority: task.Priority,
+				Due:      taskDateStr,
+			})
+		}
+	}
+
+	return data
+}
+
+// saveExchange stores the user message and the mentor reply, then lets the
+// summarizer catch up on the thread in the background
+func (s *AIService) saveExchange(userID int, threadID int, userMessage, aiResponse string) {
+	s.ChatRepo.SaveMessage(userID, threadID, "user", userMessage)
+	s.ChatRepo.SaveMessage(userID, threadID, "assistant", aiResponse)
 
-	return aiResponse, nil
+	s.Summarizer.RefreshAsync(userID, threadID)
 }
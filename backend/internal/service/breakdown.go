@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/llm"
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+// maxSubtasks caps how many subtasks a single breakdown may add
+const maxSubtasks = 10
+
+var ErrInvalidSubtasks = errors.New("invalid subtasks")
+
+var breakdownSchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"subtasks": {
+			Type: "array",
+			Items: &llm.Schema{
+				Type: "object",
+				Properties: map[string]*llm.Schema{
+					"title":       {Type: "string"},
+					"description": {Type: "string"},
+					"due_date":    {Type: "string", Description: "YYYY-MM-DD"},
+				},
+				Required: []string{"title"},
+			},
+		},
+	},
+	Required: []string{"subtasks"},
+}
+
+type breakdownPromptData struct {
+	Today       string
+	Title       string
+	Description string
+	Priority    string
+	Due         string
+	MaxSubtasks int
+}
+
+// BreakdownTask asks the model to split a task into concrete subtasks. Nothing is
+// stored, the client shows the suggestion and sends back what the user keeps.
+func (s *AIService) BreakdownTask(ctx context.Context, userID, taskID int) (*models.TaskBreakdown, error) {
+	if err := s.Usage.Check(userID); err != nil {
+		return nil, err
+	}
+
+	task, err := s.TaskRepo.GetByID(taskID, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data := breakdownPromptData{
+		Today:       time.Now().Format("2006-01-02"),
+		Title:       task.Title,
+		Description: task.Description,
+		Priority:    task.Priority,
+		MaxSubtasks: maxSubtasks,
+	}
+
+	if task.DueDate != nil {
+		data.Due = task.DueDate.Format("2006-01-02")
+	}
+
+	prompt, err := s.Prompts.Render("breakdown.tmpl", data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := s.generate(ctx, userID, FeatureBreakdown, &llm.Request{Prompt: prompt, ResponseSchema: breakdownSchema})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var answer struct {
+		Subtasks []models.SubtaskSuggestion `json:"subtasks"`
+	}
+
+	if err := json.Unmarshal([]byte(reply.Text), &answer); err != nil {
+		return nil, fmt.Errorf("model returned an invalid breakdown: %w", err)
+	}
+
+	breakdown := &models.TaskBreakdown{
+		TaskID:   task.ID,
+		Title:    task.Title,
+		Subtasks: []models.SubtaskSuggestion{},
+	}
+
+	for _, suggestion := range answer.Subtasks {
+		suggestion.Title = strings.TrimSpace(suggestion.Title)
+
+		if suggestion.Title == "" {
+			continue
+		}
+
+		//a date the model got wrong is dropped rather than failing the whole breakdown
+		if _, err := time.Parse("2006-01-02", suggestion.DueDate); err != nil {
+			suggestion.DueDate = ""
+		}
+
+		breakdown.Subtasks = append(breakdown.Subtasks, suggestion)
+
+		if len(breakdown.Subtasks) == maxSubtasks {
+			break
+		}
+	}
+
+	return breakdown, nil
+}
+
+// AcceptBreakdown stores the subtasks the user kept as children of the task
+func (s *AIService) AcceptBreakdown(userID, taskID int, suggestions []models.SubtaskSuggestion) ([]models.Task, error) {
+	parent, err := s.TaskRepo.GetByID(taskID, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	subtasks := []models.Task{}
+
+	for _, suggestion := range suggestions {
+		title := strings.TrimSpace(suggestion.Title)
+
+		if title == "" {
+			continue
+		}
+
+		subtask := models.Task{
+			Title:       title,
+			Description: strings.TrimSpace(suggestion.Description),
+			Status:      "todo",
+			Priority:    parent.Priority,
+		}
+
+		if suggestion.DueDate != "" {
+			dueDate, err := time.Parse("2006-01-02", suggestion.DueDate)
+
+			if err != nil {
+				return nil, fmt.Errorf("%w: due_date %q, expected YYYY-MM-DD", ErrInvalidSubtasks, suggestion.DueDate)
+			}
+
+			subtask.DueDate = &dueDate
+		}
+
+		subtasks = append(subtasks, subtask)
+	}
+
+	if len(subtasks) == 0 {
+		return nil, fmt.Errorf("%w: at least one subtask with a title is required", ErrInvalidSubtasks)
+	}
+
+	if len(subtasks) > maxSubtasks {
+		return nil, fmt.Errorf("%w: at most %d can be added at once", ErrInvalidSubtasks, maxSubtasks)
+	}
+
+	if err := s.mentorTasks().CreateSubtasks(parent, subtasks); err != nil {
+		return nil, err
+	}
+
+	return subtasks, nil
+}
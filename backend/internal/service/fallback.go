@@ -0,0 +1,77 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/prompts"
+)
+
+// fallbackReply is what the mentor says when the model cannot be reached: the
+// numbers from the task stats and the one task to start with. It is not saved
+// to the chat history, so the user can simply ask again later.
+func (s *AIService) fallbackReply(userID int, actions []models.MentorAction) string {
+	allTasks, _ := s.TaskRepo.GetAll(userID)
+
+	reply := FallbackReply(MentorPromptData(allTasks, time.Now()))
+
+	if len(actions) > 0 {
+		reply = actionsSummary(actions) + "\n\n" + reply
+	}
+
+	return reply
+}
+
+// FallbackReply builds the canned mentor reply from the task part of the prompt data
+func FallbackReply(data prompts.MentorData) string {
+	var reply strings.Builder
+
+	reply.WriteString("I can't reach my full mentor brain right now, so here is the short version. ")
+
+	stats := data.Stats
+	fmt.Fprintf(&reply, "Today you have %d task(s) due and %d done", stats.DueToday, stats.CompletedToday)
+
+	if stats.Overdue > 0 {
+		fmt.Fprintf(&reply, ", plus %d overdue", stats.Overdue)
+	}
+
+	reply.WriteString(". ")
+
+	if next, ok := nextTask(data); ok {
+		fmt.Fprintf(&reply, "Start with %q and finish it before anything else.", next.Title)
+	} else if stats.DueToday > 0 {
+		reply.WriteString("Everything due today is done. Good work, pick tomorrow's first task now.")
+	} else {
+		reply.WriteString("Nothing is due today, so choose one task that moves a goal forward and do it.")
+	}
+
+	return reply.String()
+}
+
+// nextTask picks the most urgent open task: overdue first, then today's, highest priority first
+func nextTask(data prompts.MentorData) (prompts.TaskLine, bool) {
+	rank := map[string]int{"high": 0, "medium": 1, "low": 2}
+	var best prompts.TaskLine
+	found := false
+
+	for _, group := range [][]prompts.TaskLine{data.OverdueTasks, data.TodayTasks} {
+		for _, task := range group {
+			if task.Done {
+				continue
+			}
+
+			if !found || rank[task.Priority] < rank[best.Priority] {
+				best = task
+				found = true
+			}
+		}
+
+		if found {
+			return best, true
+		}
+	}
+
+	return best, false
+}
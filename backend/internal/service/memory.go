@@ -0,0 +1,82 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+// maxPromptMemories caps how many memories go into one mentor prompt
+const maxPromptMemories = 12
+
+// alwaysRelevant kinds describe who the user wants to be, so they go into every prompt
+var alwaysRelevant = map[string]bool{
+	"goal":     true,
+	"identity": true,
+}
+
+// selectMemories picks the memories worth showing the mentor for userMessage.
+// Goals and identity statements always make it in; the rest are ranked by how many
+// words they share with the message, ties going to the most recently updated.
+func selectMemories(memories []models.MentorMemory, userMessage string) []models.MentorMemory {
+	words := significantWords(userMessage)
+
+	type scored struct {
+		memory models.MentorMemory
+		score  int
+	}
+
+	candidates := make([]scored, 0, len(memories))
+
+	for _, memory := range memories {
+		score := 0
+
+		for word := range significantWords(memory.Content) {
+			if words[word] {
+				score++
+			}
+		}
+
+		if alwaysRelevant[memory.Kind] {
+			score += 100
+		}
+
+		if score > 0 {
+			candidates = append(candidates, scored{memory, score})
+		}
+	}
+
+	//memories arrive newest first, a stable sort keeps that order for equal scores
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	selected := []models.MentorMemory{}
+
+	for _, candidate := range candidates {
+		if len(selected) == maxPromptMemories {
+			break
+		}
+
+		selected = append(selected, candidate.memory)
+	}
+
+	return selected
+}
+
+// significantWords lowercases text and returns its words of four letters or more,
+// which drops most filler without needing a stop word list
+func significantWords(text string) map[string]bool {
+	words := map[string]bool{}
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}) {
+		if len(word) >= 4 {
+			words[word] = true
+		}
+	}
+
+	return words
+}
@@ -0,0 +1,473 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/llm"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/repository"
+)
+
+// maxToolRounds caps how many times in a row the model may call tools before it has to answer
+const maxToolRounds = 4
+
+var taskIDParam = &llm.Schema{Type: "integer", Description: "ID of the task, as shown in the task details"}
+
+var dueDateParam = &llm.Schema{Type: "string", Description: "Due date as YYYY-MM-DD"}
+
+// mentorTools are the task operations the mentor may ask the server to run for the user
+var mentorTools = []llm.Tool{
+	{
+		Name:        "create_task",
+		Description: "Create a new task for the user.",
+		Parameters: &llm.Schema{
+			Type: "object",
+			Properties: map[string]*llm.Schema{
+				"title":       {Type: "string", Description: "Short task title"},
+				"description": {Type: "string", Description: "Optional details"},
+				"priority":    {Type: "string", Enum: models.TaskPriorities},
+				"due_date":    dueDateParam,
+			},
+			Required: []string{"title"},
+		},
+	},
+	{
+		Name:        "reschedule_task",
+		Description: "Move an existing task to a new due date.",
+		Parameters: &llm.Schema{
+			Type: "object",
+			Properties: map[string]*llm.Schema{
+				"task_id":  taskIDParam,
+				"due_date": dueDateParam,
+			},
+			Required: []string{"task_id", "due_date"},
+		},
+	},
+	{
+		Name:        "set_task_status",
+		Description: "Change the status of an existing task.",
+		Parameters: &llm.Schema{
+			Type: "object",
+			Properties: map[string]*llm.Schema{
+				"task_id": taskIDParam,
+				"status":  {Type: "string", Enum: models.TaskStatuses},
+			},
+			Required: []string{"task_id", "status"},
+		},
+	},
+	{
+		Name:        "break_into_subtasks",
+		Description: "Split an existing task into smaller concrete subtasks.",
+		Parameters: &llm.Schema{
+			Type: "object",
+			Properties: map[string]*llm.Schema{
+				"task_id": taskIDParam,
+				"subtasks": {
+					Type: "array",
+					Items: &llm.Schema{
+						Type: "object",
+						Properties: map[string]*llm.Schema{
+							"title":    {Type: "string"},
+							"due_date": dueDateParam,
+						},
+						Required: []string{"title"},
+					},
+				},
+			},
+			Required: []string{"task_id", "subtasks"},
+		},
+	},
+	{
+		Name:        "remember",
+		Description: "Save a durable fact about the user to long-term memory: a goal they state, who they want to be, an excuse they keep making, their preferred work hours, or another lasting fact.",
+		Parameters: &llm.Schema{
+			Type: "object",
+			Properties: map[string]*llm.Schema{
+				"kind":    {Type: "string", Enum: models.MemoryKinds},
+				"content": {Type: "string", Description: "The fact in one short sentence, e.g. \"Wants to run a marathon by June\""},
+			},
+			Required: []string{"kind", "content"},
+		},
+	},
+	{
+		Name:        "delete_task",
+		Description: "Delete a task. This is not done straight away: the user has to confirm it in the app.",
+		Parameters: &llm.Schema{
+			Type:       "object",
+			Properties: map[string]*llm.Schema{"task_id": taskIDParam},
+			Required:   []string{"task_id"},
+		},
+	},
+}
+
+// destructiveTools only run after the user confirms them
+var destructiveTools = map[string]bool{
+	"delete_task": true,
+}
+
+// runToolCall executes a tool call from the model on behalf of userID, or parks it
+// for confirmation if it is destructive
+func (s *AIService) runToolCall(userID int, call llm.ToolCall) models.MentorAction {
+	if !destructiveTools[call.Name] {
+		return s.executeTool(userID, call.Name, call.Args)
+	}
+
+	action := models.MentorAction{Tool: call.Name, Args: call.Args, Status: models.ActionNeedsConfirmation}
+
+	summary, err := s.describeTool(userID, call.Name, call.Args)
+
+	if err != nil {
+		action.Status = models.ActionFailed
+		action.Summary = err.Error()
+		return action
+	}
+
+	pending := models.PendingAction{UserID: userID, Tool: call.Name, Args: call.Args, Summary: summary}
+
+	if err := s.ActionRepo.Create(&pending); err != nil {
+		action.Status = models.ActionFailed
+		action.Summary = "could not save the action for confirmation: " + err.Error()
+		return action
+	}
+
+	action.Summary = summary + " (waiting for your confirmation)"
+	action.PendingActionID = pending.ID
+
+	return action
+}
+
+// ConfirmAction runs a pending destructive action the user has approved. The action
+// is claimed before it runs, so it runs once; one that failed goes back to pending so
+// it can be retried.
+func (s *AIService) ConfirmAction(userID, id int) (*models.MentorAction, error) {
+	//claiming it first means a confirmation racing this one gets ErrNotFound and runs nothing
+	pending, err := s.ActionRepo.Resolve(id, userID, "confirmed")
+
+	if err != nil {
+		return nil, err
+	}
+
+	action := s.executeTool(userID, pending.Tool, pending.Args)
+	action.PendingActionID = pending.ID
+
+	//a failed action waits again, the user can retry or cancel it
+	if action.Status != models.ActionDone {
+		if err := s.ActionRepo.Reopen(id, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &action, nil
+}
+
+func (s *AIService) CancelAction(userID, id int) (*models.PendingAction, error) {
+	return s.ActionRepo.Resolve(id, userID, "cancelled")
+}
+
+func (s *AIService) executeTool(userID int, name string, args map[string]any) models.MentorAction {
+	action := models.MentorAction{Tool: name, Args: args}
+
+	var err error
+
+	switch name {
+	case "create_task":
+		action.TaskID, action.Summary, err = s.toolCreateTask(userID, args)
+	case "reschedule_task":
+		action.TaskID, action.Summary, err = s.toolRescheduleTask(userID, args)
+	case "set_task_status":
+		action.TaskID, action.Summary, err = s.toolSetTaskStatus(userID, args)
+	case "break_into_subtasks":
+		action.TaskID, action.Summary, err = s.toolBreakIntoSubtasks(userID, args)
+	case "delete_task":
+		action.TaskID, action.Summary, err = s.toolDeleteTask(userID, args)
+	case "remember":
+		action.Summary, err = s.toolRemember(userID, args)
+	default:
+		err = fmt.Errorf("unknown tool %q", name)
+	}
+
+	if err != nil {
+		action.Status = models.ActionFailed
+		action.Summary = err.Error()
+		return action
+	}
+
+	action.Status = models.ActionDone
+
+	return action
+}
+
+// describeTool explains what a destructive tool call would do, checking the task exists
+func (s *AIService) describeTool(userID int, name string, args map[string]any) (string, error) {
+	task, err := s.toolTask(userID, args)
+
+	if err != nil {
+		return "", err
+	}
+
+	switch name {
+	case "delete_task":
+		return fmt.Sprintf("Delete task %q", task.Title), nil
+	}
+
+	return fmt.Sprintf("Run %s on task %q", name, task.Title), nil
+}
+
+func (s *AIService) toolCreateTask(userID int, args map[string]any) (int, string, error) {
+	title := argString(args, "title")
+
+	if title == "" {
+		return 0, "", errors.New("title is required")
+	}
+
+	dueDate, err := argDate(args, "due_date")
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	priority := argString(args, "priority")
+
+	if priority == "" {
+		priority = "medium"
+	}
+
+	if !models.IsTaskPriority(priority) {
+		return 0, "", fmt.Errorf("invalid priority %q", priority)
+	}
+
+	task := models.Task{
+		UserID:      userID,
+		Title:       title,
+		Description: argString(args, "description"),
+		Status:      "todo",
+		Priority:    priority,
+		DueDate:     dueDate,
+	}
+
+	if err := s.mentorTasks().Create(&task); err != nil {
+		return 0, "", err
+	}
+
+	return task.ID, fmt.Sprintf("Created task %q", task.Title), nil
+}
+
+func (s *AIService) toolRescheduleTask(userID int, args map[string]any) (int, string, error) {
+	task, err := s.toolTask(userID, args)
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	dueDate, err := argDate(args, "due_date")
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	if dueDate == nil {
+		return 0, "", errors.New("due_date is required")
+	}
+
+	if err := s.mentorTasks().Reschedule(task.ID, userID, dueDate); err != nil {
+		return 0, "", err
+	}
+
+	return task.ID, fmt.Sprintf("Moved %q to %s", task.Title, dueDate.Format("2006-01-02")), nil
+}
+
+func (s *AIService) toolSetTaskStatus(userID int, args map[string]any) (int, string, error) {
+	task, err := s.toolTask(userID, args)
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	status := argString(args, "status")
+
+	if !models.IsTaskStatus(status) {
+		return 0, "", fmt.Errorf("invalid status %q", status)
+	}
+
+	change, err := s.mentorTasks().UpdateStatus(task.ID, status, userID)
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	summary := fmt.Sprintf("Marked %q as %s", task.Title, status)
+
+	if change.NextOccurrence != nil {
+		summary += fmt.Sprintf(", the next one is due %s", change.NextOccurrence.DueDate.Format("2006-01-02"))
+	}
+
+	if change.Parent != nil {
+		summary += fmt.Sprintf(", %q is now %s", change.Parent.Title, change.Parent.Status)
+	}
+
+	return task.ID, summary, nil
+}
+
+func (s *AIService) toolBreakIntoSubtasks(userID int, args map[string]any) (int, string, error) {
+	parent, err := s.toolTask(userID, args)
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	subtasks, _ := args["subtasks"].([]any)
+
+	if len(subtasks) == 0 {
+		return 0, "", errors.New("at least one subtask is required")
+	}
+
+	children := []models.Task{}
+
+	for _, item := range subtasks {
+		subtaskArgs, ok := item.(map[string]any)
+
+		if !ok || argString(subtaskArgs, "title") == "" {
+			continue
+		}
+
+		dueDate, err := argDate(subtaskArgs, "due_date")
+
+		if err != nil {
+			return 0, "", err
+		}
+
+		children = append(children, models.Task{
+			Title:    argString(subtaskArgs, "title"),
+			Status:   "todo",
+			Priority: parent.Priority,
+			DueDate:  dueDate,
+		})
+	}
+
+	if len(children) == 0 {
+		return 0, "", errors.New("every subtask needs a title")
+	}
+
+	if err := s.mentorTasks().CreateSubtasks(parent, children); err != nil {
+		return 0, "", err
+	}
+
+	return parent.ID, fmt.Sprintf("Split %q into %d subtasks", parent.Title, len(children)), nil
+}
+
+func (s *AIService) toolDeleteTask(userID int, args map[string]any) (int, string, error) {
+	task, err := s.toolTask(userID, args)
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	if err := s.mentorTasks().Delete(task.ID, userID); err != nil {
+		return 0, "", err
+	}
+
+	return task.ID, fmt.Sprintf("Deleted task %q", task.Title), nil
+}
+
+func (s *AIService) toolRemember(userID int, args map[string]any) (string, error) {
+	memory := models.MentorMemory{
+		UserID:  userID,
+		Kind:    argString(args, "kind"),
+		Content: argString(args, "content"),
+		Source:  "mentor",
+	}
+
+	if !models.IsMemoryKind(memory.Kind) {
+		return "", fmt.Errorf("invalid memory kind %q", memory.Kind)
+	}
+
+	if memory.Content == "" {
+		return "", errors.New("content is required")
+	}
+
+	if err := s.MemoryRepo.Create(&memory); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Remembered (%s): %s", memory.Kind, memory.Content), nil
+}
+
+// toolTask loads the task named by the task_id argument, scoped to the user
+func (s *AIService) toolTask(userID int, args map[string]any) (*models.Task, error) {
+	taskID := argInt(args, "task_id")
+
+	task, err := s.TaskRepo.GetByID(taskID, userID)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("task %d not found", taskID)
+	}
+
+	return task, err
+}
+
+// mentorTasks changes tasks on the mentor's behalf, so their history says who made the change
+func (s *AIService) mentorTasks() *repository.TaskRepository {
+	return s.TaskRepo.WithSource(models.SourceMentor)
+}
+
+// toolResult is what the model is told about an action it asked for
+func toolResult(call llm.ToolCall, action models.MentorAction) llm.ToolResult {
+	result := map[string]any{
+		"status":  action.Status,
+		"summary": action.Summary,
+	}
+
+	if action.TaskID != 0 {
+		result["task_id"] = action.TaskID
+	}
+
+	return llm.ToolResult{CallID: call.ID, Name: call.Name, Result: result}
+}
+
+// actionsSummary is used as the reply when the model acted without saying anything
+func actionsSummary(actions []models.MentorAction) string {
+	summaries := make([]string, 0, len(actions))
+
+	for _, action := range actions {
+		summaries = append(summaries, action.Summary)
+	}
+
+	return strings.Join(summaries, ". ") + "."
+}
+
+func argString(args map[string]any, name string) string {
+	value, _ := args[name].(string)
+	return strings.TrimSpace(value)
+}
+
+// argInt reads a number argument, JSON decoding hands those over as float64
+func argInt(args map[string]any, name string) int {
+	switch value := args[name].(type) {
+	case float64:
+		return int(value)
+	case int:
+		return value
+	}
+
+	return 0
+}
+
+// argDate reads an optional YYYY-MM-DD (or RFC 3339) date argument
+func argDate(args map[string]any, name string) (*time.Time, error) {
+	value := argString(args, name)
+
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return &date, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid %s %q, expected YYYY-MM-DD", name, value)
+}
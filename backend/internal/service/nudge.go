@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/llm"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/prompts"
+)
+
+const (
+	FeatureNudge = "nudge"
+
+	// a configured time is still honoured this long after it passed, so a late
+	// tick or a restart does not swallow the nudge
+	nudgeWindow = time.Hour
+
+	maxNudgeTasks = 5
+
+	nudgePendingHighPriority = "pending_high_priority"
+	nudgeStreakAtRisk        = "streak_at_risk"
+)
+
+type nudgePromptData struct {
+	Now    string
+	Reason string
+	Streak int
+	Tasks  []prompts.TaskLine
+}
+
+// RunNudges sends the nudges due at now. Each user, day and configured time is
+// claimed in the database first, so a nudge is considered at most once per day
+// however often this runs. A failed nudge gives its claim back to be retried on a
+// later tick, and one user's failure does not hold up the others.
+func (s *AIService) RunNudges(ctx context.Context, now time.Time) error {
+	all, err := s.NudgeRepo.ListEnabled()
+
+	if err != nil {
+		return err
+	}
+
+	today := now.Format("2006-01-02")
+
+	for _, settings := range all {
+		for _, slot := range settings.Times {
+			at, err := time.ParseInLocation("2006-01-02 15:04", today+" "+slot, now.Location())
+
+			if err != nil || now.Before(at) || now.Sub(at) > nudgeWindow {
+				continue
+			}
+
+			runID, claimed, err := s.NudgeRepo.ClaimRun(settings.UserID, today, slot)
+
+			if err != nil {
+				log.Printf("Failed to claim nudge for user %d at %s: %v\n", settings.UserID, slot, err)
+				continue
+			}
+
+			if !claimed {
+				continue
+			}
+
+			if err := s.nudge(ctx, settings.UserID, runID, now); err != nil {
+				log.Printf("Nudge for user %d at %s failed: %v\n", settings.UserID, slot, err)
+
+				if err := s.NudgeRepo.ReleaseRun(runID); err != nil {
+					log.Printf("Failed to release nudge run %d: %v\n", runID, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// nudge looks at the user's tasks and, if something needs attention, writes to them
+func (s *AIService) nudge(ctx context.Context, userID, runID int, now time.Time) error {
+	allTasks, err := s.TaskRepo.GetAll(userID)
+
+	if err != nil {
+		return err
+	}
+
+	data := NudgeData(allTasks, now)
+
+	if data.Reason == "" {
+		return s.NudgeRepo.FinishRun(runID, "", 0)
+	}
+
+	message := s.nudgeMessage(ctx, userID, data)
+
+	threadID, err := s.ResolveThread(userID, 0)
+
+	if err != nil {
+		return err
+	}
+
+	chatID, err := s.ChatRepo.SaveNudge(userID, threadID, message)
+
+	if err != nil {
+		return err
+	}
+
+	//the message is out, so the run must keep its claim even if recording it fails
+	if err := s.NudgeRepo.FinishRun(runID, data.Reason, chatID); err != nil {
+		log.Printf("Failed to finish nudge run %d: %v\n", runID, err)
+	}
+
+	return nil
+}
+
+// nudgeMessage has the model write the nudge, falling back to a canned one when
+// the user is out of quota or the model fails
+func (s *AIService) nudgeMessage(ctx context.Context, userID int, data nudgePromptData) string {
+	if err := s.Usage.Check(userID); err != nil {
+		return cannedNudge(data)
+	}
+
+	prompt, err := s.Prompts.Render("nudge.tmpl", data)
+
+	if err != nil {
+		log.Printf("Failed to render nudge prompt: %v\n", err)
+		return cannedNudge(data)
+	}
+
+	reply, err := s.generate(ctx, userID, FeatureNudge, &llm.Request{Prompt: prompt})
+
+	if err != nil || strings.TrimSpace(reply.Text) == "" {
+		return cannedNudge(data)
+	}
+
+	return strings.TrimSpace(reply.Text)
+}
+
+// NudgeData decides whether the user needs a nudge at now and why. Reason is empty
+// when all is well: no high priority task due today or overdue is still open, and
+// the streak is either safe for today or there is none.
+func NudgeData(allTasks []models.Task, now time.Time) nudgePromptData {
+	today := now.Format("2006-01-02")
+	days := onTimeDays(allTasks)
+
+	data := nudgePromptData{Now: now.Format("15:04"), Streak: streakOn(days, now)}
+
+	var urgent, dueToday []prompts.TaskLine
+
+	for _, task := range allTasks {
+		if task.Status == "complete" || task.DueDate == nil {
+			continue
+		}
+
+		due := task.DueDate.Format("2006-01-02")
+
+		if due > today {
+			continue
+		}
+
+		line := prompts.TaskLine{ID: task.ID, Title: task.Title, Priority: task.Priority, Due: due}
+
+		if task.Priority == "high" {
+			urgent = append(urgent, line)
+		} else if due == today {
+			dueToday = append(dueToday, line)
+		}
+	}
+
+	switch {
+	case len(urgent) > 0:
+		data.Reason = nudgePendingHighPriority
+		data.Tasks = urgent
+	//only a task due today can still be finished on time and save the streak
+	case data.Streak > 0 && !days[today] && len(dueToday) > 0:
+		data.Reason = nudgeStreakAtRisk
+		data.Tasks = dueToday
+	}
+
+	sort.SliceStable(data.Tasks, func(i, j int) bool {
+		return data.Tasks[i].Due < data.Tasks[j].Due
+	})
+
+	if len(data.Tasks) > maxNudgeTasks {
+		data.Tasks = data.Tasks[:maxNudgeTasks]
+	}
+
+	return data
+}
+
+func cannedNudge(data nudgePromptData) string {
+	first := data.Tasks[0].Title
+
+	if data.Reason == nudgeStreakAtRisk {
+		return fmt.Sprintf("Your %d-day streak is on the line today. Finish %q and keep it alive, start with the first five minutes now.", data.Streak, first)
+	}
+
+	return fmt.Sprintf("%q is still open and it matters. Give it the next 25 minutes, just the first step.", first)
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/llm"
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+// maxPlanTasks caps how many open tasks are offered to the planner
+const maxPlanTasks = 40
+
+// planSchema is the JSON the model must answer a plan request with
+var planSchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"items": {
+			Type: "array",
+			Items: &llm.Schema{
+				Type: "object",
+				Properties: map[string]*llm.Schema{
+					"task_id":          {Type: "integer"},
+					"start_time":       {Type: "string", Description: "HH:MM, 24 hour clock"},
+					"duration_minutes": {Type: "integer"},
+					"reason":           {Type: "string"},
+				},
+				Required: []string{"task_id", "start_time", "duration_minutes"},
+			},
+		},
+		"deferrals": {
+			Type: "array",
+			Items: &llm.Schema{
+				Type: "object",
+				Properties: map[string]*llm.Schema{
+					"task_id":      {Type: "integer"},
+					"new_due_date": {Type: "string", Description: "YYYY-MM-DD"},
+					"reason":       {Type: "string"},
+				},
+				Required: []string{"task_id", "new_due_date"},
+			},
+		},
+		"notes": {Type: "string"},
+	},
+	Required: []string{"items", "deferrals", "notes"},
+}
+
+type planTask struct {
+	ID       int
+	Title    string
+	Priority string
+	Status   string
+	Due      string
+	Overdue  bool
+	dueAt    *time.Time
+}
+
+type planPromptData struct {
+	Today                  string
+	Weekday                string
+	Now                    string
+	Tasks                  []planTask
+	AverageCompletedPerDay float64
+	ActiveDays             int
+}
+
+// PlanToday asks the model for an ordered, time-boxed plan of the user's day and stores it
+func (s *AIService) PlanToday(ctx context.Context, userID int) (*models.DailyPlan, error) {
+	if err := s.Usage.Check(userID); err != nil {
+		return nil, err
+	}
+
+	allTasks, err := s.TaskRepo.GetAll(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	heatmap, err := s.TaskRepo.GetMonthlyHeatmapData(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	data := planPromptData{
+		Today:   now.Format("2006-01-02"),
+		Weekday: now.Weekday().String(),
+		Now:     now.Format("15:04"),
+		Tasks:   openPlanTasks(allTasks, now),
+	}
+
+	completed := 0
+
+	for _, count := range heatmap {
+		completed += count
+	}
+
+	if len(heatmap) > 0 {
+		data.ActiveDays = len(heatmap)
+		data.AverageCompletedPerDay = float64(completed) / float64(len(heatmap))
+	}
+
+	prompt, err := s.Prompts.Render("plan.tmpl", data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := s.generate(ctx, userID, FeaturePlan, &llm.Request{Prompt: prompt, ResponseSchema: planSchema})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var answer struct {
+		Items     []models.PlanItem     `json:"items"`
+		Deferrals []models.PlanDeferral `json:"deferrals"`
+		Notes     string                `json:"notes"`
+	}
+
+	if err := json.Unmarshal([]byte(reply.Text), &answer); err != nil {
+		return nil, fmt.Errorf("model returned an invalid plan: %w", err)
+	}
+
+	plan := &models.DailyPlan{
+		UserID:   userID,
+		PlanDate: data.Today,
+		Notes:    answer.Notes,
+	}
+
+	plan.Items, plan.Deferrals = checkPlan(answer.Items, answer.Deferrals, data.Tasks, now)
+
+	if err := s.PlanRepo.Create(plan); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// TodayPlan returns the latest plan made for today
+func (s *AIService) TodayPlan(userID int) (*models.DailyPlan, error) {
+	return s.PlanRepo.GetLatest(userID, time.Now().Format("2006-01-02"))
+}
+
+// AcceptPlan applies a plan: every deferred task is moved to its new due date.
+// It returns the number of tasks rescheduled and the ids of the deferrals skipped.
+func (s *AIService) AcceptPlan(userID, planID int) (*models.DailyPlan, int, []int, error) {
+	plan, err := s.PlanRepo.GetByID(planID, userID)
+
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	//the user accepted the plan, so the moves are theirs rather than the mentor's;
+	//accepting and rescheduling happen together, a failure leaves the plan to accept again
+	rescheduled, skipped, err := s.TaskRepo.WithSource(models.SourceUser).ApplyPlan(plan)
+
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	return plan, rescheduled, skipped, nil
+}
+
+// openPlanTasks picks the tasks worth planning: open ones, most urgent first
+func openPlanTasks(allTasks []models.Task, now time.Time) []planTask {
+	today := now.Format("2006-01-02")
+	tasks := []planTask{}
+
+	for _, task := range allTasks {
+		if task.Status == "complete" {
+			continue
+		}
+
+		line := planTask{
+			ID:       task.ID,
+			Title:    task.Title,
+			Priority: task.Priority,
+			Status:   task.Status,
+			dueAt:    task.DueDate,
+		}
+
+		if task.DueDate != nil {
+			line.Due = task.DueDate.Format("2006-01-02")
+			line.Overdue = line.Due < today
+		}
+
+		tasks = append(tasks, line)
+	}
+
+	//overdue, then by due date (undated last), then by priority
+	priorityRank := map[string]int{"high": 0, "medium": 1, "low": 2}
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+
+		if (a.dueAt == nil) != (b.dueAt == nil) {
+			return a.dueAt != nil
+		}
+
+		if a.dueAt != nil && a.Due != b.Due {
+			return a.Due < b.Due
+		}
+
+		return priorityRank[a.Priority] < priorityRank[b.Priority]
+	})
+
+	if len(tasks) > maxPlanTasks {
+		tasks = tasks[:maxPlanTasks]
+	}
+
+	return tasks
+}
+
+// checkPlan drops whatever the model made up: unknown task IDs, bad times and
+// deferrals that do not move a task forward. Titles come from the task list.
+func checkPlan(items []models.PlanItem, deferrals []models.PlanDeferral, tasks []planTask, now time.Time) ([]models.PlanItem, []models.PlanDeferral) {
+	titles := make(map[int]string, len(tasks))
+
+	for _, task := range tasks {
+		titles[task.ID] = task.Title
+	}
+
+	planned := map[int]bool{}
+	checkedItems := []models.PlanItem{}
+
+	for _, item := range items {
+		title, ok := titles[item.TaskID]
+
+		if !ok || planned[item.TaskID] {
+			continue
+		}
+
+		if _, err := time.Parse("15:04", item.StartTime); err != nil || item.DurationMinutes <= 0 {
+			continue
+		}
+
+		item.Title = title
+		planned[item.TaskID] = true
+		checkedItems = append(checkedItems, item)
+	}
+
+	today := now.Format("2006-01-02")
+	checkedDeferrals := []models.PlanDeferral{}
+
+	for _, deferral := range deferrals {
+		title, ok := titles[deferral.TaskID]
+
+		if !ok || planned[deferral.TaskID] {
+			continue
+		}
+
+		if _, err := time.Parse("2006-01-02", deferral.NewDueDate); err != nil || deferral.NewDueDate <= today {
+			continue
+		}
+
+		deferral.Title = title
+		planned[deferral.TaskID] = true
+		checkedDeferrals = append(checkedDeferrals, deferral)
+	}
+
+	return checkedItems, checkedDeferrals
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTrashRetentionDays = 30
+	defaultUndoWindowMinutes  = 10
+)
+
+// TrashRetentionFromEnv reads TRASH_RETENTION_DAYS, 30 days when unset or invalid
+func TrashRetentionFromEnv() time.Duration {
+	return durationFromEnv("TRASH_RETENTION_DAYS", defaultTrashRetentionDays, 24*time.Hour)
+}
+
+// UndoWindowFromEnv reads UNDO_WINDOW_MINUTES, 10 minutes when unset or invalid
+func UndoWindowFromEnv() time.Duration {
+	return durationFromEnv("UNDO_WINDOW_MINUTES", defaultUndoWindowMinutes, time.Minute)
+}
+
+// durationFromEnv reads a whole number of units from key, fallback when it is unset or not positive
+func durationFromEnv(key string, fallback int, unit time.Duration) time.Duration {
+	n, err := strconv.Atoi(os.Getenv(key))
+
+	if err != nil || n < 1 {
+		n = fallback
+	}
+
+	return time.Duration(n) * unit
+}
+
+// Purger is a scheduler job removing what has been kept longer than Age, such as
+// tasks in the trash or task operations past the undo window. Purge removes what
+// is older than its cutoff and returns how much that was.
+type Purger struct {
+	What  string // what Purge removes, for the log
+	Age   time.Duration
+	Purge func(cutoff time.Time) (int64, error)
+}
+
+func NewPurger(what string, age time.Duration, purge func(cutoff time.Time) (int64, error)) *Purger {
+	return &Purger{What: what, Age: age, Purge: purge}
+}
+
+// Run purges what is older than Age at now. A missed or repeated run only moves
+// the purge to the next one.
+func (p *Purger) Run(ctx context.Context, now time.Time) error {
+	purged, err := p.Purge(now.Add(-p.Age))
+
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		log.Printf("purge: removed %d %s older than %s", purged, p.What, p.Age)
+	}
+
+	return nil
+}
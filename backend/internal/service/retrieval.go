@@ -0,0 +1,134 @@
+package service
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/prompts"
+)
+
+const (
+	// how many past tasks and messages retrieval may add to the prompt
+	maxRelatedTasks    = 6
+	maxRelatedMessages = 4
+
+	// search this many more than needed, some hits are already in the prompt
+	retrievalOverfetch = 4
+
+	maxSearchTerms     = 12
+	maxRelatedMsgChars = 300
+)
+
+// searchQuery turns a user message into a to_tsquery expression matching any of its
+// words, or "" when nothing is worth searching for. Postgres drops the stop words.
+func searchQuery(userMessage string) string {
+	terms := []string{}
+	seen := map[string]bool{}
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(userMessage), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}) {
+		//short words like "gym" matter here, unlike for memories
+		if len(word) < 3 || seen[word] {
+			continue
+		}
+
+		seen[word] = true
+		terms = append(terms, word)
+
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+
+	return strings.Join(terms, " | ")
+}
+
+// retrieve searches the user's past tasks and conversations for what relates to
+// userMessage. Messages already replayed to the model are left out. Retrieval is
+// best effort: a failing search just means no related history.
+func (s *AIService) retrieve(userID int, userMessage string, replayed []models.ChatMessage) ([]models.Task, []models.ChatMessage) {
+	query := searchQuery(userMessage)
+
+	if query == "" {
+		return nil, nil
+	}
+
+	tasks, _ := s.TaskRepo.Search(userID, query, maxRelatedTasks)
+
+	found, _ := s.ChatRepo.SearchMessages(userID, query, maxRelatedMessages+retrievalOverfetch)
+
+	inHistory := map[int]bool{}
+
+	for _, message := range replayed {
+		inHistory[message.ID] = true
+	}
+
+	messages := []models.ChatMessage{}
+
+	for _, message := range found {
+		if inHistory[message.ID] {
+			continue
+		}
+
+		messages = append(messages, message)
+
+		if len(messages) == maxRelatedMessages {
+			break
+		}
+	}
+
+	return tasks, messages
+}
+
+// relatedLines turns retrieved items into prompt lines, skipping tasks the prompt already lists
+func relatedLines(data prompts.MentorData, tasks []models.Task, messages []models.ChatMessage) ([]prompts.TaskLine, []prompts.MessageLine) {
+	listed := map[int]bool{}
+
+	for _, task := range data.TodayTasks {
+		listed[task.ID] = true
+	}
+
+	for _, task := range data.OverdueTasks {
+		listed[task.ID] = true
+	}
+
+	taskLines := []prompts.TaskLine{}
+
+	for _, task := range tasks {
+		if listed[task.ID] {
+			continue
+		}
+
+		line := prompts.TaskLine{ID: task.ID, Title: task.Title, Priority: task.Priority, Done: task.Status == "complete"}
+
+		if task.DueDate != nil {
+			line.Due = task.DueDate.Format("2006-01-02")
+		}
+
+		if task.CompletedAt != nil {
+			line.Completed = task.CompletedAt.Format("2006-01-02")
+		}
+
+		taskLines = append(taskLines, line)
+	}
+
+	messageLines := []prompts.MessageLine{}
+
+	for _, message := range messages {
+		text := strings.Join(strings.Fields(message.Message), " ")
+
+		if utf8.RuneCountInString(text) > maxRelatedMsgChars {
+			text = string([]rune(text)[:maxRelatedMsgChars]) + "..."
+		}
+
+		messageLines = append(messageLines, prompts.MessageLine{
+			Date: message.CreatedAt.Format("2006-01-02"),
+			Role: message.Role,
+			Text: text,
+		})
+	}
+
+	return taskLines, messageLines
+}
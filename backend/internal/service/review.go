@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/llm"
+	"github.com/Philip-Machar/clario/internal/models"
+)
+
+// maxReviewTitles caps the task titles listed per group in the stats and the prompt
+const maxReviewTitles = 15
+
+var ErrFutureWeek = errors.New("week has not started yet")
+
+var reviewSchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"wins":          {Type: "array", Items: &llm.Schema{Type: "string"}},
+		"patterns":      {Type: "array", Items: &llm.Schema{Type: "string"}},
+		"system_change": {Type: "string"},
+	},
+	Required: []string{"wins", "patterns", "system_change"},
+}
+
+// WeeklyReview has the mentor reflect on the week containing day and stores the review
+func (s *AIService) WeeklyReview(ctx context.Context, userID int, day time.Time) (*models.WeeklyReview, error) {
+	now := time.Now()
+	weekStart := startOfWeek(day)
+
+	if weekStart.After(now) {
+		return nil, ErrFutureWeek
+	}
+
+	if err := s.Usage.Check(userID); err != nil {
+		return nil, err
+	}
+
+	allTasks, err := s.TaskRepo.GetAll(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stats := WeekStats(allTasks, weekStart, now)
+
+	prompt, err := s.Prompts.Render("review.tmpl", stats)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := s.generate(ctx, userID, FeatureReview, &llm.Request{Prompt: prompt, ResponseSchema: reviewSchema})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var answer struct {
+		Wins         []string `json:"wins"`
+		Patterns     []string `json:"patterns"`
+		SystemChange string   `json:"system_change"`
+	}
+
+	if err := json.Unmarshal([]byte(reply.Text), &answer); err != nil {
+		return nil, fmt.Errorf("model returned an invalid review: %w", err)
+	}
+
+	review := &models.WeeklyReview{
+		UserID:       userID,
+		WeekStart:    stats.WeekStart,
+		Stats:        stats,
+		Wins:         nonEmpty(answer.Wins),
+		Patterns:     nonEmpty(answer.Patterns),
+		SystemChange: answer.SystemChange,
+	}
+
+	if err := s.ReviewRepo.Save(review); err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+// WeekStats adds up what happened to the tasks in the week starting at weekStart.
+// A week still in progress is counted up to now.
+func WeekStats(allTasks []models.Task, weekStart time.Time, now time.Time) models.WeekStats {
+	weekEnd := weekStart.AddDate(0, 0, 6)
+
+	if weekEnd.After(now) {
+		weekEnd = now
+	}
+
+	start := weekStart.Format("2006-01-02")
+	end := weekEnd.Format("2006-01-02")
+
+	stats := models.WeekStats{
+		WeekStart:      start,
+		WeekEnd:        end,
+		CompletedTasks: []string{},
+		MissedTasks:    []string{},
+		CarriedTasks:   []string{},
+	}
+
+	perDay := map[string]int{}
+	onTimeDays := onTimeDays(allTasks)
+
+	for _, task := range allTasks {
+		completed, due := "", ""
+
+		if task.CompletedAt != nil {
+			completed = task.CompletedAt.Format("2006-01-02")
+		}
+
+		if task.DueDate != nil {
+			due = task.DueDate.Format("2006-01-02")
+		}
+
+		onTime := completed != "" && due != "" && completed <= due
+
+		if completed >= start && completed <= end {
+			stats.Completed++
+			perDay[completed]++
+			stats.CompletedTasks = appendTitle(stats.CompletedTasks, task.Title)
+
+			if onTime {
+				stats.CompletedOnTime++
+			}
+		}
+
+		//due dates still ahead, today included, cannot have been missed yet
+		if due == "" || due >= now.Format("2006-01-02") || due > end {
+			continue
+		}
+
+		doneByDue := completed != "" && completed <= due
+
+		if due >= start && !doneByDue {
+			stats.Missed++
+			stats.MissedTasks = appendTitle(stats.MissedTasks, task.Title)
+		}
+
+		//still open when the week closed, so it rolls into the next one
+		if completed == "" || completed > end {
+			stats.CarriedOver++
+			stats.CarriedTasks = appendTitle(stats.CarriedTasks, task.Title)
+		}
+	}
+
+	for day, count := range perDay {
+		if count > perDay[stats.BusiestDay] || (count == perDay[stats.BusiestDay] && day < stats.BusiestDay) {
+			stats.BusiestDay = day
+		}
+	}
+
+	stats.StreakStart = streakOn(onTimeDays, weekStart.AddDate(0, 0, -1))
+	stats.StreakEnd = streakOn(onTimeDays, weekEnd)
+
+	return stats
+}
+
+// onTimeDays are the days on which at least one task was completed by its due date,
+// the days that count towards a streak
+func onTimeDays(allTasks []models.Task) map[string]bool {
+	days := map[string]bool{}
+
+	for _, task := range allTasks {
+		if task.CompletedAt == nil || task.DueDate == nil {
+			continue
+		}
+
+		completed := task.CompletedAt.Format("2006-01-02")
+
+		if completed <= task.DueDate.Format("2006-01-02") {
+			days[completed] = true
+		}
+	}
+
+	return days
+}
+
+// streakOn counts the consecutive days with an on-time completion up to day.
+// A day without one yet does not break the streak, it just is not counted.
+func streakOn(days map[string]bool, day time.Time) int {
+	if !days[day.Format("2006-01-02")] {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	streak := 0
+
+	for days[day.Format("2006-01-02")] {
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return streak
+}
+
+// startOfWeek returns midnight on the Monday of day's week
+func startOfWeek(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	year, month, date := day.Date()
+
+	return time.Date(year, month, date-offset, 0, 0, 0, 0, day.Location())
+}
+
+func appendTitle(titles []string, title string) []string {
+	if len(titles) >= maxReviewTitles {
+		return titles
+	}
+
+	return append(titles, title)
+}
+
+func nonEmpty(values []string) []string {
+	kept := []string{}
+
+	for _, value := range values {
+		if value != "" {
+			kept = append(kept, value)
+		}
+	}
+
+	return kept
+}
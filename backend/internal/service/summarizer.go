@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Philip-Machar/clario/internal/llm"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/repository"
+)
+
+const (
+	// DefaultHistoryBudget is the number of tokens the mentor prompt spends on the
+	// conversation so far, summary and raw messages together
+	DefaultHistoryBudget = 2000
+
+	// MinHistoryBudget is the smallest budget that still leaves room for a few messages
+	MinHistoryBudget = 200
+
+	// at most this share of the budget goes to the summary, the rest to raw messages
+	summaryShare = 0.25
+
+	summarizeTimeout = time.Minute
+)
+
+// Summarizer keeps a rolling summary per thread so the mentor remembers more than the
+// raw messages it can afford to replay. Raw messages always win: the newest ones are
+// replayed verbatim, and once the unsummarized backlog no longer fits in the budget
+// the oldest part of it is condensed into the summary.
+type Summarizer struct {
+	Model    llm.MentorModel
+	ChatRepo *repository.ChatRepository
+	Budget   int
+	Usage    *UsageMeter
+}
+
+func NewSummarizer(model llm.MentorModel, chatRepo *repository.ChatRepository, budget int, usage *UsageMeter) *Summarizer {
+	if budget <= 0 {
+		budget = DefaultHistoryBudget
+	}
+
+	return &Summarizer{Model: model, ChatRepo: chatRepo, Budget: budget, Usage: usage}
+}
+
+// estimateTokens approximates the token count of text, roughly four characters per token
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// History returns the summary and raw messages of a thread to put in front of the model
+func (s *Summarizer) History(userID, threadID int) (string, []models.ChatMessage) {
+	summary := ""
+	afterID := 0
+
+	if stored, err := s.ChatRepo.GetSummary(userID, threadID); err == nil {
+		summary = stored.Summary
+		afterID = stored.SummarizedThroughID
+	}
+
+	messages, _ := s.ChatRepo.GetRecentHistory(userID, threadID, afterID)
+
+	return s.BudgetHistory(summary, messages)
+}
+
+// BudgetHistory fits a summary and messages (oldest first) into the token budget.
+// The summary is trimmed to its share, keeping its end, and whatever budget it leaves
+// unused goes to raw messages, newest first.
+func (s *Summarizer) BudgetHistory(summary string, messages []models.ChatMessage) (string, []models.ChatMessage) {
+	summaryBudget := int(float64(s.Budget) * summaryShare)
+
+	//too small a share for even the "…" leaves no summary at all
+	if summaryBudget*4 <= 3 {
+		summary = ""
+	} else if estimateTokens(summary) > summaryBudget {
+		//"…" takes three bytes of the budget, and the cut must land on a rune boundary
+		cut := len(summary) - summaryBudget*4 + 3
+
+		for cut < len(summary) && !utf8.RuneStart(summary[cut]) {
+			cut++
+		}
+
+		summary = "…" + summary[cut:]
+	}
+
+	remaining := s.Budget - estimateTokens(summary)
+	start := len(messages)
+
+	for start > 0 {
+		cost := estimateTokens(messages[start-1].Message)
+
+		if cost > remaining {
+			break
+		}
+
+		remaining -= cost
+		start--
+	}
+
+	return summary, messages[start:]
+}
+
+// overflow returns the oldest messages that should be folded into the summary:
+// everything that does not fit in half the budget once the newest messages are kept.
+// Keeping only half leaves room to grow before the next condense.
+func (s *Summarizer) overflow(messages []models.ChatMessage) []models.ChatMessage {
+	total := 0
+
+	for _, message := range messages {
+		total += estimateTokens(message.Message)
+	}
+
+	rawBudget := int(float64(s.Budget) * (1 - summaryShare))
+
+	if total <= rawBudget {
+		return nil
+	}
+
+	keep := rawBudget / 2
+	end := len(messages)
+
+	for end > 0 {
+		cost := estimateTokens(messages[end-1].Message)
+
+		if cost > keep {
+			break
+		}
+
+		keep -= cost
+		end--
+	}
+
+	return messages[:end]
+}
+
+// Condense asks the model to fold messages into the previous summary
+func (s *Summarizer) Condense(ctx context.Context, previous string, messages []models.ChatMessage) (string, error) {
+	summary, _, err := s.condense(ctx, previous, messages)
+
+	return summary, err
+}
+
+// condense is Condense, also returning what the model call cost
+func (s *Summarizer) condense(ctx context.Context, previous string, messages []models.ChatMessage) (string, llm.Usage, error) {
+	maxWords := int(float64(s.Budget)*summaryShare*0.75) - 10
+
+	var transcript strings.Builder
+
+	for _, message := range messages {
+		fmt.Fprintf(&transcript, "%s: %s\n", message.Role, message.Message)
+	}
+
+	if previous == "" {
+		previous = "(none yet)"
+	}
+
+	prompt := fmt.Sprintf(`You maintain the running summary of a conversation between a user and their accountability mentor.
+Update the summary with the new messages below. Keep goals the user stated, commitments made,
+recurring excuses or patterns, and advice the mentor gave. Drop small talk.
+Write plain prose in the third person, at most %d words.
+
+CURRENT SUMMARY:
+%s
+
+NEW MESSAGES:
+%s
+UPDATED SUMMARY:`, maxWords, previous, transcript.String())
+
+	reply, err := s.Model.Generate(ctx, &llm.Request{Prompt: prompt})
+
+	if err != nil {
+		return "", llm.Usage{}, err
+	}
+
+	return strings.TrimSpace(reply.Text), reply.Usage, nil
+}
+
+// Refresh condenses the overflow of a thread into its summary, if there is any
+func (s *Summarizer) Refresh(ctx context.Context, userID, threadID int) error {
+	previous := ""
+	afterID := 0
+
+	stored, err := s.ChatRepo.GetSummary(userID, threadID)
+
+	switch {
+	case err == nil:
+		previous = stored.Summary
+		afterID = stored.SummarizedThroughID
+	case !errors.Is(err, repository.ErrNotFound):
+		return err
+	}
+
+	messages, err := s.ChatRepo.GetRecentHistory(userID, threadID, afterID)
+
+	if err != nil {
+		return err
+	}
+
+	overflow := s.overflow(messages)
+
+	if len(overflow) == 0 {
+		return nil
+	}
+
+	//summaries keep the mentor's prompt in budget, so they are metered but never refused
+	summary, usage, err := s.condense(ctx, previous, overflow)
+
+	if err != nil {
+		return err
+	}
+
+	s.Usage.Record(userID, FeatureSummary, usage)
+
+	return s.ChatRepo.SaveSummary(&models.ChatSummary{
+		ThreadID:            threadID,
+		UserID:              userID,
+		Summary:             summary,
+		SummarizedThroughID: overflow[len(overflow)-1].ID,
+	})
+}
+
+// RefreshAsync runs Refresh in the background so the user never waits on it
+func (s *Summarizer) RefreshAsync(userID, threadID int) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), summarizeTimeout)
+		defer cancel()
+
+		if err := s.Refresh(ctx, userID, threadID); err != nil {
+			log.Printf("ERROR: Failed to summarize thread %d of user %d: %v\n", threadID, userID, err)
+		}
+	}()
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Philip-Machar/clario/internal/llm"
+	"github.com/Philip-Machar/clario/internal/models"
+	"github.com/Philip-Machar/clario/internal/repository"
+)
+
+// features recorded with every model call
+const (
+	FeatureChat      = "chat"
+	FeaturePlan      = "plan"
+	FeatureBreakdown = "breakdown"
+	FeatureReview    = "review"
+	FeatureSummary   = "summary"
+)
+
+var ErrQuotaExceeded = errors.New("AI usage quota exceeded")
+
+// QuotaError says which quota ran out and when it resets
+type QuotaError struct {
+	Quota   string // daily_tokens, monthly_tokens, daily_requests or monthly_requests
+	Limit   int
+	Used    int
+	ResetAt time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: %s used %d of %d, resets at %s", ErrQuotaExceeded, e.Quota, e.Used, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// UsageMeter records the tokens every model call costs and enforces the quotas.
+// A request is one model call: a chat message that makes the mentor use tools costs several.
+// A nil *UsageMeter records nothing and allows everything.
+type UsageMeter struct {
+	Repo   *repository.UsageRepository
+	Limits models.UsageLimits
+}
+
+func NewUsageMeter(repo *repository.UsageRepository, limits models.UsageLimits) *UsageMeter {
+	return &UsageMeter{Repo: repo, Limits: limits}
+}
+
+// LimitsFromEnv reads AI_DAILY_TOKENS, AI_MONTHLY_TOKENS, AI_DAILY_REQUESTS and
+// AI_MONTHLY_REQUESTS. Unset or zero means no limit.
+func LimitsFromEnv() models.UsageLimits {
+	limit := func(name string) int {
+		value, err := strconv.Atoi(os.Getenv(name))
+
+		if err != nil || value < 0 {
+			return 0
+		}
+
+		return value
+	}
+
+	return models.UsageLimits{
+		DailyTokens:     limit("AI_DAILY_TOKENS"),
+		MonthlyTokens:   limit("AI_MONTHLY_TOKENS"),
+		DailyRequests:   limit("AI_DAILY_REQUESTS"),
+		MonthlyRequests: limit("AI_MONTHLY_REQUESTS"),
+	}
+}
+
+// Check returns a *QuotaError if the user has used up any quota
+func (m *UsageMeter) Check(userID int) error {
+	if m == nil {
+		return nil
+	}
+
+	limits := m.Limits
+
+	if limits == (models.UsageLimits{}) {
+		return nil
+	}
+
+	now := time.Now()
+	day, month := startOfDay(now), startOfMonth(now)
+
+	if limits.DailyTokens > 0 || limits.DailyRequests > 0 {
+		today, err := m.Repo.Totals(userID, day)
+
+		if err != nil {
+			return err
+		}
+
+		if err := quota("daily_tokens", limits.DailyTokens, today.TotalTokens, day.AddDate(0, 0, 1)); err != nil {
+			return err
+		}
+
+		if err := quota("daily_requests", limits.DailyRequests, today.Requests, day.AddDate(0, 0, 1)); err != nil {
+			return err
+		}
+	}
+
+	if limits.MonthlyTokens > 0 || limits.MonthlyRequests > 0 {
+		thisMonth, err := m.Repo.Totals(userID, month)
+
+		if err != nil {
+			return err
+		}
+
+		if err := quota("monthly_tokens", limits.MonthlyTokens, thisMonth.TotalTokens, month.AddDate(0, 1, 0)); err != nil {
+			return err
+		}
+
+		if err := quota("monthly_requests", limits.MonthlyRequests, thisMonth.Requests, month.AddDate(0, 1, 0)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func quota(name string, limit, used int, resetAt time.Time) error {
+	if limit > 0 && used >= limit {
+		return &QuotaError{Quota: name, Limit: limit, Used: used, ResetAt: resetAt}
+	}
+
+	return nil
+}
+
+// Record stores what a model call cost. Failing to record is logged, never fatal.
+func (m *UsageMeter) Record(userID int, feature string, usage llm.Usage) {
+	if m == nil {
+		return
+	}
+
+	if err := m.Repo.Record(userID, feature, usage.PromptTokens, usage.ResponseTokens); err != nil {
+		log.Printf("Failed to record AI usage for user %d: %v\n", userID, err)
+	}
+}
+
+// Usage reports the user's usage today and this month, with the limits
+func (m *UsageMeter) Usage(userID int) (*models.UserUsage, error) {
+	now := time.Now()
+
+	today, err := m.Repo.Totals(userID, startOfDay(now))
+
+	if err != nil {
+		return nil, err
+	}
+
+	thisMonth, err := m.Repo.Totals(userID, startOfMonth(now))
+
+	if err != nil {
+		return nil, err
+	}
+
+	byFeature, err := m.Repo.TotalsByFeature(userID, startOfMonth(now))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UserUsage{Today: today, Month: thisMonth, ByFeature: byFeature, Limits: m.Limits}, nil
+}
+
+// Report adds up every user's usage in [from, to)
+func (m *UsageMeter) Report(from, to time.Time) (*models.UsageReport, error) {
+	users, err := m.Repo.PerUser(from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.UsageReport{
+		From:  from.Format("2006-01-02"),
+		To:    to.Format("2006-01-02"),
+		Users: users,
+	}
+
+	for _, user := range users {
+		report.Total.Requests += user.Requests
+		report.Total.PromptTokens += user.PromptTokens
+		report.Total.ResponseTokens += user.ResponseTokens
+		report.Total.TotalTokens += user.TotalTokens
+	}
+
+	return report, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
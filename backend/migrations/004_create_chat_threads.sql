@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS chat_threads (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE ai_chats ADD COLUMN thread_id INT REFERENCES chat_threads(id) ON DELETE CASCADE;
+
+-- existing conversations move into one "General" thread per user
+INSERT INTO chat_threads (user_id, title)
+SELECT DISTINCT user_id, 'General' FROM ai_chats;
+
+UPDATE ai_chats SET thread_id = chat_threads.id
+FROM chat_threads
+WHERE chat_threads.user_id = ai_chats.user_id;
+
+ALTER TABLE ai_chats ALTER COLUMN thread_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_ai_chats_thread ON ai_chats (thread_id, id);
+CREATE INDEX IF NOT EXISTS idx_chat_threads_user ON chat_threads (user_id, updated_at);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE ai_chats DROP COLUMN IF EXISTS thread_id;
+DROP TABLE IF EXISTS chat_threads;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- rolling summary of the older messages of a thread, maintained by the summarizer
+CREATE TABLE IF NOT EXISTS chat_summaries (
+    thread_id INT PRIMARY KEY REFERENCES chat_threads(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    summary TEXT NOT NULL,
+    summarized_through_id INT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chat_summaries;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS mentor_memories (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL
+        CHECK (kind IN ('goal', 'identity', 'excuse', 'work_hours', 'fact')),
+    content TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT 'user'
+        CHECK (source IN ('user', 'mentor')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mentor_memories_user ON mentor_memories (user_id);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS mentor_memories;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE ai_chats ADD COLUMN IF NOT EXISTS proactive BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE ai_chats ADD COLUMN IF NOT EXISTS read_at TIMESTAMP NULL;
+
+CREATE INDEX IF NOT EXISTS idx_ai_chats_unread_nudges ON ai_chats (user_id) WHERE proactive AND read_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS nudge_settings (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    times TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- one row per user, day and configured time: claiming it is what makes a nudge run once
+CREATE TABLE IF NOT EXISTS nudge_runs (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    run_date DATE NOT NULL,
+    slot TEXT NOT NULL,
+    reason TEXT NULL,
+    chat_id INT NULL REFERENCES ai_chats(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, run_date, slot)
+);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS nudge_runs;
+DROP TABLE IF EXISTS nudge_settings;
+DROP INDEX IF EXISTS idx_ai_chats_unread_nudges;
+ALTER TABLE ai_chats DROP COLUMN IF EXISTS read_at;
+ALTER TABLE ai_chats DROP COLUMN IF EXISTS proactive;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- recurrence is an RRULE-style schedule, e.g. FREQ=WEEKLY;BYDAY=MO,WE.
+-- series_id links the occurrences of a recurring task, it is the id of the first one
+-- (which may since have been deleted, so it is deliberately not a foreign key).
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS recurrence TEXT NULL;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS series_id INT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_series ON tasks (series_id, due_date);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_tasks_series;
+ALTER TABLE tasks DROP COLUMN IF EXISTS series_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS recurrence;
+-- +goose StatementEnd
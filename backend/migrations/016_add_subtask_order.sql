@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- parent_id exists since 005, subtasks now also keep a manual order
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+
+-- when set, the task completes itself once all its subtasks are complete
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS auto_complete BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- existing subtasks keep the order they were created in
+UPDATE tasks SET position = ordered.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY id) - 1 AS position
+    FROM tasks
+    WHERE parent_id IS NOT NULL
+) AS ordered
+WHERE tasks.id = ordered.id;
+
+DROP INDEX IF EXISTS idx_tasks_parent;
+CREATE INDEX IF NOT EXISTS idx_tasks_parent ON tasks (parent_id, position);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_tasks_parent;
+CREATE INDEX IF NOT EXISTS idx_tasks_parent ON tasks (parent_id);
+ALTER TABLE tasks DROP COLUMN IF EXISTS auto_complete;
+ALTER TABLE tasks DROP COLUMN IF EXISTS position;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    color TEXT NOT NULL DEFAULT '#6b7280',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- tag names are unique per user, ignoring case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_user_name ON tags (user_id, LOWER(name));
+
+CREATE TABLE IF NOT EXISTS task_tags (
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    tag_id INT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (task_id, tag_id)
+);
+
+-- the primary key covers lookups by task, this one filtering and counting by tag
+CREATE INDEX IF NOT EXISTS idx_task_tags_tag ON task_tags (tag_id, task_id);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS task_tags;
+DROP TABLE IF EXISTS tags;
+-- +goose StatementEnd
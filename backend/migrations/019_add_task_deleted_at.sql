@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- deleted tasks stay in the trash until restored, deleted for good or purged
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_trash ON tasks (user_id, deleted_at) WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM tasks WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_tasks_trash;
+ALTER TABLE tasks DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
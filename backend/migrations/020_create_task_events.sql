@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- one row per changed field of a task, values as text: dates as YYYY-MM-DD, tags as their names
+CREATE TABLE IF NOT EXISTS task_events (
+    id SERIAL PRIMARY KEY,
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    field TEXT NOT NULL,
+    old_value TEXT NULL,
+    new_value TEXT NULL,
+    source TEXT NOT NULL DEFAULT 'user' CHECK (source IN ('user', 'api', 'mentor', 'system')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_events_task ON task_events (task_id, created_at);
+
+-- counting how often the user's tasks were pushed back
+CREATE INDEX IF NOT EXISTS idx_task_events_due ON task_events (user_id, task_id) WHERE field = 'due_date';
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS task_events;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the undo stack: each operation keeps the rows of the tasks it touched as they were
+-- before and after every step of it, see repository/task_operation_repository.go
+CREATE TABLE IF NOT EXISTS task_operations (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL,
+    steps JSONB NOT NULL DEFAULT '[]',
+    undone_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_operations_user ON task_operations (user_id, id);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS task_operations;
+-- +goose StatementEnd
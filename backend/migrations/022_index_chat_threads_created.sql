@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- thread listings page newest first by creation, which unlike updated_at never moves
+CREATE INDEX IF NOT EXISTS idx_chat_threads_user_created ON chat_threads (user_id, created_at, id);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_chat_threads_user_created;
+-- +goose StatementEnd
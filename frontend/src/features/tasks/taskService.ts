@@ -1,10 +1,20 @@
 import api from '../../services/api';
-import type { Task, StreakResponse } from '../../types';
+import type { Task, TaskListResponse, StreakResponse } from '../../types';
 
-// Fetch all tasks for the authenticated user
+// Fetch all tasks for the authenticated user, following the pages of /tasks
 export const fetchTasks = async (): Promise<Task[]> => {
-  const response = await api.get<Task[]>('/tasks');
-  return response.data;
+  const tasks: Task[] = [];
+  let cursor: string | undefined;
+
+  do {
+    const response = await api.get<TaskListResponse>('/tasks', {
+      params: { limit: 200, cursor },
+    });
+    tasks.push(...response.data.tasks);
+    cursor = response.data.next_cursor;
+  } while (cursor);
+
+  return tasks;
 };
 
 // Create a new task
@@ -24,6 +24,12 @@ export interface Task {
 }
 
 // 3. The API Response Contracts
+// one page of GET /tasks
+export interface TaskListResponse {
+    tasks: Task[];
+    next_cursor?: string;
+}
+
 // response when you log in
 export interface AuthResponse {
     token: string;